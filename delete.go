@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"go.seankhliao.com/fbhuploader/deploy"
+)
+
+// deleteVersion runs the delete-version subcommand,
+// deleting a single version of a site.
+func deleteVersion(args []string) int {
+	var o options
+	var force bool
+	fs := flag.NewFlagSet("fbhuploader delete-version", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: fbhuploader delete-version [flags] VERSION")
+		fs.PrintDefaults()
+	}
+	o.siteFlags(fs)
+	fs.BoolVar(&force, "force", false, "delete the version even if it's released to live, taking the site down")
+	fs.BoolVar(&force, "allow-live", false, "same as -force")
+	fs.BoolVar(&o.yes, "yes", false, "don't ask for confirmation before deleting")
+	fs.BoolVar(&o.json, "json", false, "output errors as json")
+	fs.Parse(args)
+	o.setup()
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+	err := runDeleteVersion(ctx, o, fs.Arg(0), force)
+	if err != nil {
+		printError(o.json, err)
+		return exitCode(err)
+	}
+	return 0
+}
+
+func runDeleteVersion(ctx context.Context, o options, version string, force bool) error {
+	do := o.deployOptions()
+	site, err := versionSite(do, "delete-version", version)
+	if err != nil {
+		return err
+	}
+
+	var d deploy.Deployer
+	err = d.DeleteVersion(ctx, do, site, version, force)
+	if err != nil {
+		return err
+	}
+	if !o.json {
+		fmt.Fprintln(os.Stdout, "deleted", version)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package deploy
+
+import (
+	"context"
+
+	firebasehosting "google.golang.org/api/firebasehosting/v1beta1"
+)
+
+// API is the subset of the firebase hosting api used by a deploy,
+// so it can be replaced by a fake.
+// Errors for missing resources should be a *googleapi.Error with code 404.
+type API interface {
+	// GetSite gets a site by its full name, projects/PROJECT/sites/SITE.
+	GetSite(ctx context.Context, name string) (*firebasehosting.Site, error)
+
+	CreateVersion(ctx context.Context, site string, version *firebasehosting.Version) (*firebasehosting.Version, error)
+	PatchVersion(ctx context.Context, version *firebasehosting.Version, updateMask string) (*firebasehosting.Version, error)
+	DeleteVersion(ctx context.Context, version string) error
+	// ListVersions calls fn with each page of versions of site.
+	ListVersions(ctx context.Context, site string, fn func([]*firebasehosting.Version) error) error
+	// PopulateFiles adds the path to hash mapping to version,
+	// returning the hashes that still need to be uploaded.
+	PopulateFiles(ctx context.Context, version string, files map[string]string) (*firebasehosting.PopulateVersionFilesResponse, error)
+	// ListFiles calls fn with each page of files in version.
+	ListFiles(ctx context.Context, version string, fn func([]*firebasehosting.VersionFile) error) error
+
+	// ListReleases calls fn with each page of releases of site, newest first.
+	ListReleases(ctx context.Context, site string, fn func([]*firebasehosting.Release) error) error
+	// CreateRelease releases version to the site's live channel,
+	// with the message and type from release.
+	CreateRelease(ctx context.Context, site, version string, release *firebasehosting.Release) (*firebasehosting.Release, error)
+	// CreateChannelRelease releases version to a channel.
+	CreateChannelRelease(ctx context.Context, channel, version string, release *firebasehosting.Release) (*firebasehosting.Release, error)
+
+	GetChannel(ctx context.Context, channel string) (*firebasehosting.Channel, error)
+	// ListChannels calls fn with each page of channels of site.
+	ListChannels(ctx context.Context, site string, fn func([]*firebasehosting.Channel) error) error
+	CreateChannel(ctx context.Context, site, channelID string, channel *firebasehosting.Channel) (*firebasehosting.Channel, error)
+	PatchChannel(ctx context.Context, channel *firebasehosting.Channel, updateMask string) (*firebasehosting.Channel, error)
+}
+
+// NewAPI implements API with the firebase hosting api client.
+func NewAPI(s *firebasehosting.Service) API {
+	return &service{s}
+}
+
+type service struct {
+	s *firebasehosting.Service
+}
+
+func (s *service) GetSite(ctx context.Context, name string) (*firebasehosting.Site, error) {
+	return s.s.Projects.Sites.Get(name).Context(ctx).Do()
+}
+
+func (s *service) CreateVersion(ctx context.Context, site string, version *firebasehosting.Version) (*firebasehosting.Version, error) {
+	return s.s.Sites.Versions.Create(site, version).Context(ctx).Do()
+}
+
+func (s *service) PatchVersion(ctx context.Context, version *firebasehosting.Version, updateMask string) (*firebasehosting.Version, error) {
+	call := s.s.Sites.Versions.Patch(version.Name, version)
+	if updateMask != "" {
+		call = call.UpdateMask(updateMask)
+	}
+	return call.Context(ctx).Do()
+}
+
+func (s *service) DeleteVersion(ctx context.Context, version string) error {
+	_, err := s.s.Sites.Versions.Delete(version).Context(ctx).Do()
+	return err
+}
+
+func (s *service) ListVersions(ctx context.Context, site string, fn func([]*firebasehosting.Version) error) error {
+	return s.s.Sites.Versions.List(site).PageSize(100).Pages(ctx, func(res *firebasehosting.ListVersionsResponse) error {
+		return fn(res.Versions)
+	})
+}
+
+func (s *service) PopulateFiles(ctx context.Context, version string, files map[string]string) (*firebasehosting.PopulateVersionFilesResponse, error) {
+	return s.s.Sites.Versions.PopulateFiles(version, &firebasehosting.PopulateVersionFilesRequest{
+		Files: files,
+	}).Context(ctx).Do()
+}
+
+func (s *service) ListFiles(ctx context.Context, version string, fn func([]*firebasehosting.VersionFile) error) error {
+	return s.s.Sites.Versions.Files.List(version).PageSize(1000).Pages(ctx, func(res *firebasehosting.ListVersionFilesResponse) error {
+		return fn(res.Files)
+	})
+}
+
+func (s *service) ListReleases(ctx context.Context, site string, fn func([]*firebasehosting.Release) error) error {
+	return s.s.Sites.Releases.List(site).PageSize(100).Pages(ctx, func(res *firebasehosting.ListReleasesResponse) error {
+		return fn(res.Releases)
+	})
+}
+
+func (s *service) CreateRelease(ctx context.Context, site, version string, release *firebasehosting.Release) (*firebasehosting.Release, error) {
+	return s.s.Sites.Releases.Create(site, release).VersionName(version).Context(ctx).Do()
+}
+
+func (s *service) CreateChannelRelease(ctx context.Context, channel, version string, release *firebasehosting.Release) (*firebasehosting.Release, error) {
+	return s.s.Sites.Channels.Releases.Create(channel, release).VersionName(version).Context(ctx).Do()
+}
+
+func (s *service) GetChannel(ctx context.Context, channel string) (*firebasehosting.Channel, error) {
+	return s.s.Sites.Channels.Get(channel).Context(ctx).Do()
+}
+
+func (s *service) ListChannels(ctx context.Context, site string, fn func([]*firebasehosting.Channel) error) error {
+	return s.s.Sites.Channels.List(site).PageSize(100).Pages(ctx, func(res *firebasehosting.ListChannelsResponse) error {
+		return fn(res.Channels)
+	})
+}
+
+func (s *service) CreateChannel(ctx context.Context, site, channelID string, channel *firebasehosting.Channel) (*firebasehosting.Channel, error) {
+	return s.s.Sites.Channels.Create(site, channel).ChannelId(channelID).Context(ctx).Do()
+}
+
+func (s *service) PatchChannel(ctx context.Context, channel *firebasehosting.Channel, updateMask string) (*firebasehosting.Channel, error) {
+	return s.s.Sites.Channels.Patch(channel.Name, channel).UpdateMask(updateMask).Context(ctx).Do()
+}
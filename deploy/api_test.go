@@ -0,0 +1,312 @@
+package deploy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	firebasehosting "google.golang.org/api/firebasehosting/v1beta1"
+	"google.golang.org/api/googleapi"
+)
+
+// fakeAPI is an in memory firebase hosting api,
+// with an upload endpoint served by an httptest.Server.
+type fakeAPI struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	n        int
+	versions map[string]*firebasehosting.Version
+	files    map[string]map[string]string
+	releases map[string][]*firebasehosting.Release
+	channels map[string]*firebasehosting.Channel
+	// stored is the contents uploaded to any version, by hash
+	stored map[string]bool
+	// posted records the hash of each successful upload request
+	posted []string
+	// patched records each PatchVersion call
+	patched []*firebasehosting.Version
+	// failUploads responds to uploads of a hash with a status code
+	failUploads map[string]int
+}
+
+func newFakeAPI(t *testing.T) *fakeAPI {
+	f := &fakeAPI{
+		versions:    make(map[string]*firebasehosting.Version),
+		files:       make(map[string]map[string]string),
+		releases:    make(map[string][]*firebasehosting.Release),
+		channels:    make(map[string]*firebasehosting.Channel),
+		stored:      make(map[string]bool),
+		failUploads: make(map[string]int),
+	}
+	f.server = httptest.NewServer(http.HandlerFunc(f.upload))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+// deployer returns a Deployer using f.
+func (f *fakeAPI) deployer() *Deployer {
+	return &Deployer{HTTPClient: f.server.Client(), API: f, Project: "test-project"}
+}
+
+// upload stores the body posted to .../files/HASH if it matches the hash.
+func (f *fakeAPI) upload(w http.ResponseWriter, r *http.Request) {
+	hash := path.Base(r.URL.Path)
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if code := f.failUploads[hash]; code != 0 {
+		http.Error(w, "upload failed", code)
+		return
+	}
+	sum := sha256.Sum256(b)
+	if hex.EncodeToString(sum[:]) != hash {
+		http.Error(w, "contents don't match hash", http.StatusBadRequest)
+		return
+	}
+	f.stored[hash] = true
+	f.posted = append(f.posted, hash)
+}
+
+// storedConfig returns conf as the api stores it,
+// with false fields dropped and defaults filled in.
+func storedConfig(conf *firebasehosting.ServingConfig) *firebasehosting.ServingConfig {
+	if conf == nil {
+		return nil
+	}
+	c := *conf
+	c.ForceSendFields = nil
+	c.Rewrites = nil
+	for _, rw := range conf.Rewrites {
+		rw := *rw
+		if rw.Function != "" && rw.FunctionRegion == "" {
+			rw.FunctionRegion = "us-central1"
+		}
+		c.Rewrites = append(c.Rewrites, &rw)
+	}
+	return &c
+}
+
+func notFound(name string) error {
+	return &googleapi.Error{Code: http.StatusNotFound, Message: name + " not found"}
+}
+
+func (f *fakeAPI) GetSite(ctx context.Context, name string) (*firebasehosting.Site, error) {
+	return &firebasehosting.Site{Name: name}, nil
+}
+
+func (f *fakeAPI) CreateVersion(ctx context.Context, site string, version *firebasehosting.Version) (*firebasehosting.Version, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.n++
+	v := *version
+	v.Name = fmt.Sprintf("%s/versions/v%03d", site, f.n)
+	v.Status = "CREATED"
+	v.CreateTime = time.Now().UTC().Format(time.RFC3339Nano)
+	v.Config = storedConfig(v.Config)
+	f.versions[v.Name] = &v
+	f.files[v.Name] = make(map[string]string)
+	out := v
+	return &out, nil
+}
+
+func (f *fakeAPI) PatchVersion(ctx context.Context, version *firebasehosting.Version, updateMask string) (*firebasehosting.Version, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	v, ok := f.versions[version.Name]
+	if !ok {
+		return nil, notFound(version.Name)
+	}
+	patch := *version
+	f.patched = append(f.patched, &patch)
+	if updateMask == "" {
+		updateMask = "status"
+	}
+	for _, field := range strings.Split(updateMask, ",") {
+		switch field {
+		case "status":
+			v.Status = version.Status
+		case "config":
+			v.Config = storedConfig(version.Config)
+		case "labels":
+			v.Labels = version.Labels
+		}
+	}
+	out := *v
+	return &out, nil
+}
+
+func (f *fakeAPI) DeleteVersion(ctx context.Context, version string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.versions[version]; !ok {
+		return notFound(version)
+	}
+	delete(f.versions, version)
+	delete(f.files, version)
+	return nil
+}
+
+func (f *fakeAPI) ListVersions(ctx context.Context, site string, fn func([]*firebasehosting.Version) error) error {
+	f.mu.Lock()
+	var vs []*firebasehosting.Version
+	for name, v := range f.versions {
+		if strings.HasPrefix(name, site+"/versions/") {
+			out := *v
+			vs = append(vs, &out)
+		}
+	}
+	f.mu.Unlock()
+	sort.Slice(vs, func(i, j int) bool { return vs[i].Name < vs[j].Name })
+	return fn(vs)
+}
+
+// PopulateFiles requires an upload for each path whose contents aren't stored yet,
+// repeating hashes shared by multiple paths.
+func (f *fakeAPI) PopulateFiles(ctx context.Context, version string, files map[string]string) (*firebasehosting.PopulateVersionFilesResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	vfiles, ok := f.files[version]
+	if !ok {
+		return nil, notFound(version)
+	}
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	var required []string
+	for _, p := range paths {
+		vfiles[p] = files[p]
+		if !f.stored[files[p]] {
+			required = append(required, files[p])
+		}
+	}
+	return &firebasehosting.PopulateVersionFilesResponse{
+		UploadRequiredHashes: required,
+		UploadUrl:            f.server.URL + "/upload/" + version + "/files",
+	}, nil
+}
+
+func (f *fakeAPI) ListFiles(ctx context.Context, version string, fn func([]*firebasehosting.VersionFile) error) error {
+	f.mu.Lock()
+	var vfs []*firebasehosting.VersionFile
+	for p, hash := range f.files[version] {
+		vfs = append(vfs, &firebasehosting.VersionFile{Path: p, Hash: hash})
+	}
+	f.mu.Unlock()
+	return fn(vfs)
+}
+
+func (f *fakeAPI) ListReleases(ctx context.Context, site string, fn func([]*firebasehosting.Release) error) error {
+	f.mu.Lock()
+	rs := append([]*firebasehosting.Release{}, f.releases[site]...)
+	f.mu.Unlock()
+	return fn(rs)
+}
+
+func (f *fakeAPI) CreateRelease(ctx context.Context, site, version string, release *firebasehosting.Release) (*firebasehosting.Release, error) {
+	return f.CreateChannelRelease(ctx, site+"/channels/live", version, release)
+}
+
+func (f *fakeAPI) CreateChannelRelease(ctx context.Context, channel, version string, release *firebasehosting.Release) (*firebasehosting.Release, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	v, ok := f.versions[version]
+	if !ok {
+		return nil, notFound(version)
+	} else if v.Status != "FINALIZED" {
+		return nil, &googleapi.Error{Code: http.StatusBadRequest, Message: version + " isn't finalized"}
+	}
+	site := channel[:strings.Index(channel, "/channels/")]
+	f.n++
+	rel := *release
+	rel.Name = fmt.Sprintf("%s/releases/r%03d", channel, f.n)
+	snapshot := *v
+	rel.Version = &snapshot
+	f.releases[site] = append([]*firebasehosting.Release{&rel}, f.releases[site]...)
+	ch, ok := f.channels[channel]
+	if !ok {
+		ch = &firebasehosting.Channel{Name: channel}
+		f.channels[channel] = ch
+	}
+	ch.Release = &rel
+	out := rel
+	return &out, nil
+}
+
+// GetChannel always finds the live channel, which exists even before the first release.
+func (f *fakeAPI) GetChannel(ctx context.Context, channel string) (*firebasehosting.Channel, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ch, ok := f.channels[channel]
+	if !ok {
+		if !strings.HasSuffix(channel, "/channels/live") {
+			return nil, notFound(channel)
+		}
+		ch = &firebasehosting.Channel{Name: channel}
+	}
+	out := *ch
+	return &out, nil
+}
+
+func (f *fakeAPI) ListChannels(ctx context.Context, site string, fn func([]*firebasehosting.Channel) error) error {
+	f.mu.Lock()
+	var chs []*firebasehosting.Channel
+	for name, ch := range f.channels {
+		if strings.HasPrefix(name, site+"/channels/") {
+			out := *ch
+			chs = append(chs, &out)
+		}
+	}
+	f.mu.Unlock()
+	sort.Slice(chs, func(i, j int) bool { return chs[i].Name < chs[j].Name })
+	return fn(chs)
+}
+
+func (f *fakeAPI) CreateChannel(ctx context.Context, site, channelID string, channel *firebasehosting.Channel) (*firebasehosting.Channel, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ch := *channel
+	ch.Name = site + "/channels/" + channelID
+	ch.Url = "https://" + path.Base(site) + "--" + channelID + ".web.app"
+	f.channels[ch.Name] = &ch
+	out := ch
+	return &out, nil
+}
+
+func (f *fakeAPI) PatchChannel(ctx context.Context, channel *firebasehosting.Channel, updateMask string) (*firebasehosting.Channel, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ch, ok := f.channels[channel.Name]
+	if !ok {
+		return nil, notFound(channel.Name)
+	}
+	ch.Ttl = channel.Ttl
+	out := *ch
+	return &out, nil
+}
+
+// uploads returns the hashes posted so far, and clears them.
+func (f *fakeAPI) uploads() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	posted := f.posted
+	f.posted = nil
+	sort.Strings(posted)
+	return posted
+}
@@ -0,0 +1,223 @@
+package deploy
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// openArchive opens a .zip, .tar, .tar.gz, or .tgz file as a filesystem,
+// to be read in place of the public directory.
+// Tar archives are read into memory, as they can't be accessed randomly.
+// Directories and entries other than regular files are skipped.
+func openArchive(name string) (fs.FS, func() error, error) {
+	if strings.HasSuffix(name, ".zip") {
+		zr, err := zip.OpenReader(name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("open archive: %w", err)
+		}
+		fsys, err := readZip(&zr.Reader)
+		if err != nil {
+			zr.Close()
+			return nil, nil, fmt.Errorf("read archive %s: %w", name, err)
+		}
+		return fsys, zr.Close, nil
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open archive: %w", err)
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(name, ".gz") || strings.HasSuffix(name, ".tgz") {
+		gr, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, nil, fmt.Errorf("read archive %s: %w", name, err)
+		}
+		defer gr.Close()
+		r = gr
+	}
+	fsys, err := readTar(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read archive %s: %w", name, err)
+	}
+	return fsys, func() error { return nil }, nil
+}
+
+func readZip(zr *zip.Reader) (fs.FS, error) {
+	fsys := newArchiveFS()
+	for _, f := range zr.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		err := fsys.add(f.Name, f.FileInfo(), f.Open)
+		if err != nil {
+			return nil, err
+		}
+	}
+	fsys.index()
+	return fsys, nil
+}
+
+func readTar(r io.Reader) (fs.FS, error) {
+	fsys := newArchiveFS()
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			fsys.index()
+			return fsys, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		} else if hdr.Size > maxFileSize {
+			return nil, fmt.Errorf("%s is larger than the %d byte limit", hdr.Name, maxFileSize)
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", hdr.Name, err)
+		}
+		err = fsys.add(hdr.Name, hdr.FileInfo(), func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(b)), nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// archiveFS is a read only filesystem of the regular files in an archive,
+// with the directories implied by their paths.
+type archiveFS map[string]*archiveEntry
+
+type archiveEntry struct {
+	info fs.FileInfo
+	// open is nil for directories
+	open func() (io.ReadCloser, error)
+	// children are the sorted entries of a directory, set by index
+	children []fs.DirEntry
+}
+
+func newArchiveFS() archiveFS {
+	return archiveFS{".": {info: archiveDirInfo(".")}}
+}
+
+// add adds a file and its parent directories, a later file replaces an earlier one.
+func (a archiveFS) add(name string, info fs.FileInfo, open func() (io.ReadCloser, error)) error {
+	p := path.Clean(strings.TrimPrefix(name, "/"))
+	if !fs.ValidPath(p) || p == "." {
+		return fmt.Errorf("invalid path %q", name)
+	} else if e, ok := a[p]; ok && e.open == nil {
+		return fmt.Errorf("%s is both a file and a directory", p)
+	}
+	a[p] = &archiveEntry{info: info, open: open}
+	for dir := path.Dir(p); dir != "."; dir = path.Dir(dir) {
+		if e, ok := a[dir]; ok && e.open != nil {
+			return fmt.Errorf("%s is both a file and a directory", dir)
+		} else if !ok {
+			a[dir] = &archiveEntry{info: archiveDirInfo(path.Base(dir))}
+		}
+	}
+	return nil
+}
+
+// index lists the entries of each directory once all files are added.
+func (a archiveFS) index() {
+	for p, e := range a {
+		if p != "." {
+			parent := a[path.Dir(p)]
+			parent.children = append(parent.children, fs.FileInfoToDirEntry(e.info))
+		}
+	}
+	for _, e := range a {
+		sort.Slice(e.children, func(i, j int) bool {
+			return e.children[i].Name() < e.children[j].Name()
+		})
+	}
+}
+
+func (a archiveFS) lookup(op, name string) (*archiveEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	e, ok := a[name]
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return e, nil
+}
+
+func (a archiveFS) Open(name string) (fs.File, error) {
+	e, err := a.lookup("open", name)
+	if err != nil {
+		return nil, err
+	} else if e.open == nil {
+		return &archiveDir{entry: e}, nil
+	}
+	rc, err := e.open()
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &archiveFile{rc, e.info}, nil
+}
+
+func (a archiveFS) Stat(name string) (fs.FileInfo, error) {
+	e, err := a.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return e.info, nil
+}
+
+type archiveFile struct {
+	io.ReadCloser
+	info fs.FileInfo
+}
+
+func (f *archiveFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+type archiveDir struct {
+	entry  *archiveEntry
+	offset int
+}
+
+func (d *archiveDir) Stat() (fs.FileInfo, error) { return d.entry.info, nil }
+func (d *archiveDir) Close() error               { return nil }
+
+func (d *archiveDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.entry.info.Name(), Err: errors.New("is a directory")}
+}
+
+func (d *archiveDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	entries := d.entry.children[d.offset:]
+	if n > 0 && len(entries) == 0 {
+		return nil, io.EOF
+	} else if n > 0 && len(entries) > n {
+		entries = entries[:n]
+	}
+	d.offset += len(entries)
+	return entries, nil
+}
+
+// archiveDirInfo describes a directory implied by the paths in an archive.
+type archiveDirInfo string
+
+func (d archiveDirInfo) Name() string       { return string(d) }
+func (d archiveDirInfo) Size() int64        { return 0 }
+func (d archiveDirInfo) Mode() fs.FileMode  { return fs.ModeDir | 0o755 }
+func (d archiveDirInfo) ModTime() time.Time { return time.Time{} }
+func (d archiveDirInfo) IsDir() bool        { return true }
+func (d archiveDirInfo) Sys() any           { return nil }
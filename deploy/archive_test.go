@@ -0,0 +1,93 @@
+package deploy
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// archiveFiles are the regular files in the test archives,
+// which also have a directory and a symlink entry.
+var archiveFiles = map[string]string{
+	"index.html":      "<h1>hello</h1>",
+	"css/site.css":    "body { color: red }",
+	"a/b/c/deep.txt":  "deep",
+	"/leading/slash":  "slash",
+	"empty/file.html": "",
+}
+
+func testArchive(t *testing.T, fsys fs.FS) {
+	t.Helper()
+	var want []string
+	for p, content := range archiveFiles {
+		p = strings.TrimPrefix(p, "/")
+		want = append(want, p)
+		b, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			t.Error(err)
+		} else if string(b) != content {
+			t.Errorf("%s = %q, want %q", p, b, content)
+		}
+	}
+	if _, err := fs.Stat(fsys, "link"); err == nil {
+		t.Error("symlink entry wasn't skipped")
+	}
+	err := fstest.TestFS(fsys, want...)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReadTar(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: "css/", Mode: 0o755})
+	tw.WriteHeader(&tar.Header{Typeflag: tar.TypeSymlink, Name: "link", Linkname: "index.html"})
+	for p, content := range archiveFiles {
+		tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: p, Mode: 0o644, Size: int64(len(content))})
+		tw.Write([]byte(content))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	fsys, err := readTar(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testArchive(t, fsys)
+}
+
+func TestOpenArchiveZip(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "site.zip")
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	zw.Create("css/")
+	link := &zip.FileHeader{Name: "link"}
+	link.SetMode(fs.ModeSymlink | 0o777)
+	w, _ := zw.CreateHeader(link)
+	w.Write([]byte("index.html"))
+	for p, content := range archiveFiles {
+		w, _ := zw.Create(p)
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	fsys, closeArchive, err := openArchive(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeArchive()
+	testArchive(t, fsys)
+}
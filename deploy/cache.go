@@ -0,0 +1,146 @@
+package deploy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	cacheFileName = ".fbhuploader-cache.json"
+	// cacheVersion is bumped whenever the way files are compressed changes,
+	// invalidating previously computed hashes.
+	cacheVersion = 2
+)
+
+// hashCache remembers the hashes computed for files under a public directory
+// between runs, keyed by their path, size, modification time,
+// and the compression level used.
+// A nil *hashCache caches nothing.
+type hashCache struct {
+	file   string
+	public string
+
+	mu    sync.Mutex
+	all   cacheFile
+	files map[string]cacheEntry
+}
+
+// cacheFile is the on disk format,
+// shared by all the public directories of a config.
+type cacheFile struct {
+	Version int                              `json:"version"`
+	Publics map[string]map[string]cacheEntry `json:"publics"`
+}
+
+type cacheEntry struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	Level   int       `json:"level"`
+	Hash    string    `json:"hash"`
+	GzSize  int64     `json:"gzSize"`
+}
+
+// loadCache reads the cache from dir, for files under public.
+// A missing or outdated cache results in an empty cache.
+// If fresh is set, the existing cache is ignored and will be overwritten.
+func loadCache(dir, public string, fresh bool) (*hashCache, error) {
+	absPublic, err := filepath.Abs(public)
+	if err != nil {
+		return nil, fmt.Errorf("resolve %s: %w", public, err)
+	}
+	c := &hashCache{
+		file:   filepath.Join(dir, cacheFileName),
+		public: absPublic,
+		all: cacheFile{
+			Version: cacheVersion,
+			Publics: make(map[string]map[string]cacheEntry),
+		},
+		files: make(map[string]cacheEntry),
+	}
+
+	b, err := os.ReadFile(c.file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("read cache: %w", err)
+	}
+	var prev cacheFile
+	err = json.Unmarshal(b, &prev)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal cache %s: %w", c.file, err)
+	}
+	if prev.Version == cacheVersion && prev.Publics != nil {
+		// keep the entries for other public directories
+		c.all.Publics = prev.Publics
+		if files := prev.Publics[absPublic]; files != nil && !fresh {
+			c.files = files
+		}
+	}
+	return c, nil
+}
+
+func (c *hashCache) lookup(p string, fi fs.FileInfo, level int) (*fileContent, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.files[p]
+	if !ok || e.Size != fi.Size() || !e.ModTime.Equal(fi.ModTime()) || e.Level != level {
+		return nil, false
+	}
+	return &fileContent{hash: e.Hash, size: e.GzSize}, true
+}
+
+func (c *hashCache) store(p string, fi fs.FileInfo, level int, content *fileContent) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files[p] = cacheEntry{
+		Size:    fi.Size(),
+		ModTime: fi.ModTime(),
+		Level:   level,
+		Hash:    content.hash,
+		GzSize:  content.size,
+	}
+}
+
+// prune drops entries for files that weren't seen in the latest walk.
+func (c *hashCache) prune(pathToHash map[string]string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for p := range c.files {
+		if _, ok := pathToHash["/"+p]; !ok {
+			delete(c.files, p)
+		}
+	}
+}
+
+func (c *hashCache) save() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.all.Publics[c.public] = c.files
+	b, err := json.Marshal(c.all)
+	if err != nil {
+		return fmt.Errorf("marshal cache: %w", err)
+	}
+	err = os.WriteFile(c.file, b, 0o644)
+	if err != nil {
+		return fmt.Errorf("write cache: %w", err)
+	}
+	return nil
+}
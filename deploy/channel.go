@@ -0,0 +1,92 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	firebasehosting "google.golang.org/api/firebasehosting/v1beta1"
+	"google.golang.org/api/googleapi"
+)
+
+const releasePollInterval = 2 * time.Second
+
+// releaseChannel releases version to a preview channel,
+// creating the channel if it doesn't exist yet.
+// A non zero expires (re)sets the channel's time to live.
+// It returns the created release and the channel's url.
+func releaseChannel(ctx context.Context, client API, site, channelID string, expires time.Duration, version, message, releaseType string) (*firebasehosting.Release, string, error) {
+	channel, err := ensureChannel(ctx, client, site, channelID, expires)
+	if err != nil {
+		return nil, "", err
+	}
+
+	rel, err := client.CreateChannelRelease(ctx, channel.Name, version, &firebasehosting.Release{
+		Message: message,
+		Type:    releaseType,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("release %s to %s: %w", version, channel.Name, err)
+	}
+	return rel, channel.Url, nil
+}
+
+func ensureChannel(ctx context.Context, client API, site, channelID string, expires time.Duration) (*firebasehosting.Channel, error) {
+	var ttl string
+	if expires > 0 {
+		ttl = fmt.Sprintf("%ds", int64(expires.Seconds()))
+	}
+
+	name := site + "/channels/" + channelID
+	channel, err := client.GetChannel(ctx, name)
+	var gerr *googleapi.Error
+	if errors.As(err, &gerr) && gerr.Code == http.StatusNotFound {
+		channel, err = client.CreateChannel(ctx, site, channelID, &firebasehosting.Channel{
+			Ttl: ttl,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("create channel %s: %w", name, err)
+		}
+		return channel, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("get channel %s: %w", name, err)
+	}
+
+	if ttl != "" {
+		channel, err = client.PatchChannel(ctx, &firebasehosting.Channel{
+			Name: name,
+			Ttl:  ttl,
+		}, "ttl")
+		if err != nil {
+			return nil, fmt.Errorf("update expiry for channel %s: %w", name, err)
+		}
+	}
+	return channel, nil
+}
+
+// waitForRelease polls the channel until its current release
+// is for version, or gives up after timeout.
+func waitForRelease(ctx context.Context, client API, channelName, version string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for {
+		channel, err := client.GetChannel(ctx, channelName)
+		if err != nil && ctx.Err() == nil {
+			return fmt.Errorf("get channel %s: %w", channelName, err)
+		}
+		if err == nil && channel.Release != nil && channel.Release.Version != nil {
+			if channel.Release.Version.Name == version {
+				return nil
+			}
+			slog.Debug("waiting for release", "channel", channelName, "current", channel.Release.Version.Name, "want", version)
+		}
+
+		err = sleep(ctx, releasePollInterval)
+		if err != nil {
+			return fmt.Errorf("%s still isn't serving %s after %v", channelName, version, timeout)
+		}
+	}
+}
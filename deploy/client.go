@@ -0,0 +1,158 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	firebasehosting "google.golang.org/api/firebasehosting/v1beta1"
+	"google.golang.org/api/option"
+)
+
+var scopes = []string{
+	"https://www.googleapis.com/auth/cloud-platform",
+	"https://www.googleapis.com/auth/firebase",
+}
+
+// newClients creates the http client used for file uploads
+// and the firebase hosting api client,
+// both authenticated with the same credentials.
+// Credentials are read from credentialsJSON or credentialsFile if set,
+// otherwise application default credentials are used.
+// Api requests are billed to project if set.
+// The project the credentials belong to is also returned, if known.
+// Concurrency sizes the pool of kept alive connections for uploads.
+// If baseURL is set, api requests are sent there instead,
+// unauthenticated unless credentials are also set.
+// All requests are sent with userAgent,
+// and through proxy if set, otherwise the proxy from the environment.
+func newClients(ctx context.Context, credentialsFile string, credentialsJSON []byte, project, baseURL, userAgent, proxy string, concurrency int) (*http.Client, API, string, error) {
+	transport, err := uploadTransport(concurrency, proxy)
+	if err != nil {
+		return nil, nil, "", withClass(ErrConfig, err)
+	}
+	base := &http.Client{Transport: &headerTransport{"User-Agent", userAgent, transport}}
+	if baseURL != "" && credentialsFile == "" && credentialsJSON == nil {
+		opts := []option.ClientOption{option.WithEndpoint(baseURL), option.WithoutAuthentication(), option.WithUserAgent(userAgent)}
+		if proxy != "" {
+			opts = append(opts, option.WithHTTPClient(base))
+		}
+		client, err := firebasehosting.NewService(ctx, opts...)
+		if err != nil {
+			return nil, nil, "", fmt.Errorf("create firebase client: %w", err)
+		}
+		return base, NewAPI(client), "", nil
+	}
+
+	var creds *google.Credentials
+	if credentialsJSON != nil {
+		creds, err = google.CredentialsFromJSON(ctx, credentialsJSON, scopes...)
+		if err != nil {
+			return nil, nil, "", fmt.Errorf("parse credentials: %w", err)
+		}
+	} else if credentialsFile != "" {
+		b, err := os.ReadFile(credentialsFile)
+		if err != nil {
+			return nil, nil, "", fmt.Errorf("read credentials: %w", err)
+		}
+		creds, err = google.CredentialsFromJSON(ctx, b, scopes...)
+		if err != nil {
+			return nil, nil, "", fmt.Errorf("parse credentials from %s: %w", credentialsFile, err)
+		}
+	} else {
+		var err error
+		creds, err = google.FindDefaultCredentials(ctx, scopes...)
+		if err != nil {
+			return nil, nil, "", fmt.Errorf("find default credentials: %w", err)
+		}
+	}
+
+	httpClient := oauth2.NewClient(context.WithValue(ctx, oauth2.HTTPClient, base), creds.TokenSource)
+
+	opts := []option.ClientOption{option.WithCredentials(creds), option.WithUserAgent(userAgent)}
+	if project != "" {
+		opts = append(opts, option.WithQuotaProject(project))
+	}
+	if proxy != "" {
+		// the api client otherwise creates its own transport, without the proxy,
+		// and a given client replaces the credentials and quota project options
+		apiClient := httpClient
+		if project != "" {
+			apiClient = &http.Client{Transport: &headerTransport{"X-Goog-User-Project", project, httpClient.Transport}}
+		}
+		opts = append(opts, option.WithHTTPClient(apiClient))
+	}
+	if baseURL != "" {
+		opts = append(opts, option.WithEndpoint(baseURL))
+	}
+	client, err := firebasehosting.NewService(ctx, opts...)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("create firebase client: %w", err)
+	}
+	return httpClient, NewAPI(client), creds.ProjectID, nil
+}
+
+// rebaseURL replaces the scheme and host of u with those of base, if set,
+// keeping the path.
+func rebaseURL(u, base string) (string, error) {
+	if base == "" {
+		return u, nil
+	}
+	b, err := url.Parse(base)
+	if err != nil {
+		return "", fmt.Errorf("parse upload base url: %w", err)
+	}
+	pu, err := url.Parse(u)
+	if err != nil {
+		return "", fmt.Errorf("parse upload url: %w", err)
+	}
+	pu.Scheme, pu.Host = b.Scheme, b.Host
+	pu.Path = strings.TrimSuffix(b.Path, "/") + pu.Path
+	return pu.String(), nil
+}
+
+// headerTransport sets a header on each request.
+type headerTransport struct {
+	key, value string
+	rt         http.RoundTripper
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set(t.key, t.value)
+	return t.rt.RoundTrip(req)
+}
+
+// uploadTransport bounds each stage of a connection so a stalled upload fails
+// instead of hanging, and keeps enough idle connections to reuse one per worker.
+// HTTP/2 is negotiated where the server supports it,
+// multiplexing concurrent uploads over a single connection.
+// Requests go through proxy if set, otherwise the proxy from the environment.
+func uploadTransport(concurrency int, proxy string) (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if proxy != "" {
+		u, err := url.Parse(proxy)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid proxy url %q", proxy)
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+	t.DialContext = (&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	t.TLSHandshakeTimeout = 10 * time.Second
+	t.ResponseHeaderTimeout = 2 * time.Minute
+	t.IdleConnTimeout = 90 * time.Second
+	t.MaxIdleConnsPerHost = concurrency
+	// already cloned from the default transport, but required with a custom dialer
+	t.ForceAttemptHTTP2 = true
+	return t, nil
+}
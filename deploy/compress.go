@@ -0,0 +1,115 @@
+package deploy
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// precompressedExts are extensions of formats that are already compressed
+// and won't get any smaller with gzip.
+var precompressedExts = map[string]bool{
+	".7z":    true,
+	".avif":  true,
+	".br":    true,
+	".bz2":   true,
+	".gif":   true,
+	".gz":    true,
+	".heic":  true,
+	".jpeg":  true,
+	".jpg":   true,
+	".m4a":   true,
+	".mov":   true,
+	".mp3":   true,
+	".mp4":   true,
+	".ogg":   true,
+	".png":   true,
+	".webm":  true,
+	".webp":  true,
+	".woff":  true,
+	".woff2": true,
+	".xz":    true,
+	".zip":   true,
+	".zst":   true,
+}
+
+// alreadyCompressed reports whether the file at p looks like it's
+// already compressed, either by its extension, or by sniffing its contents.
+// It only peeks at br, leaving the contents in place for reading.
+func alreadyCompressed(p string, br *bufio.Reader) bool {
+	if precompressedExts[strings.ToLower(path.Ext(p))] {
+		return true
+	}
+	head, _ := br.Peek(512)
+	switch ct := http.DetectContentType(head); {
+	case strings.HasPrefix(ct, "image/") && ct != "image/svg+xml" && ct != "image/bmp" && ct != "image/x-icon",
+		strings.HasPrefix(ct, "audio/") && ct != "audio/wave" && ct != "audio/aiff",
+		strings.HasPrefix(ct, "video/"),
+		ct == "application/zip", ct == "application/x-gzip", ct == "application/x-rar-compressed",
+		ct == "font/woff", ct == "font/woff2":
+		return true
+	}
+	return false
+}
+
+// parseCompression parses a gzip compression level by name or number.
+func parseCompression(s string) (int, error) {
+	switch s {
+	case "default":
+		return gzip.DefaultCompression, nil
+	case "speed":
+		return gzip.BestSpeed, nil
+	case "best":
+		return gzip.BestCompression, nil
+	}
+	level, err := strconv.Atoi(s)
+	if err != nil || level < gzip.NoCompression || level > gzip.BestCompression {
+		return 0, fmt.Errorf("invalid compression level %q, expected default, speed, best, or 0-9", s)
+	}
+	return level, nil
+}
+
+// hashGzip writes the gzipped contents of r to w,
+// returning the hex encoded sha256 of the gzipped bytes,
+// which is how firebase hosting identifies file contents.
+func hashGzip(w io.Writer, r io.Reader, level int) (string, error) {
+	h := sha256.New()
+	gw, err := gzip.NewWriterLevel(io.MultiWriter(h, w), level)
+	if err != nil {
+		return "", err
+	}
+	// the same contents must always produce the same hash,
+	// or unchanged files would be uploaded again on every deploy.
+	// Pin the header fields that could vary (a zero ModTime writes 0, not the current time).
+	gw.Header = gzip.Header{
+		ModTime: time.Time{},
+		OS:      255, // unknown
+	}
+	_, err = io.Copy(gw, r)
+	if err != nil {
+		return "", err
+	}
+	err = gw.Close()
+	if err != nil {
+		return "", fmt.Errorf("flush gzip writer: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashCopy copies already gzipped bytes from r to w, returning their sha256.
+func hashCopy(w io.Writer, r io.Reader) (string, error) {
+	h := sha256.New()
+	_, err := io.Copy(io.MultiWriter(h, w), r)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
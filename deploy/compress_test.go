@@ -0,0 +1,83 @@
+package deploy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestHashGzip(t *testing.T) {
+	tests := []struct {
+		name  string
+		in    string
+		level int
+		hash  string
+		size  int
+	}{
+		{"empty", "", gzip.DefaultCompression, "ac73670af3abed54ac6fb4695131f4099be9fbe39d6076c5d0264a6bbdae9d83", 20},
+		{"short", "hello", gzip.DefaultCompression, "b24d4ff66724d8a7c431e5d7e227f0c9bf1cda79e480dad0be6cd297b8e25fff", 30},
+		{"short best", "hello", gzip.BestCompression, "411c0097a7824877f8ddd8be0c2fff0574bbfce5152b8b695bbb108eef4e7b68", 26},
+		{"repeated", strings.Repeat("firebase ", 100), gzip.DefaultCompression, "bf4c38a4ea53103ed4a76b5e474eaf68e562d8e5c64275fed02ca5a2d88f93c1", 38},
+		{"repeated speed", strings.Repeat("firebase ", 100), gzip.BestSpeed, "dc49cd859ed50a6685125d70af99440568b638e51b5a643536946fe4972e2745", 38},
+		{"repeated best", strings.Repeat("firebase ", 100), gzip.BestCompression, "d8442384357b7544cc8311ef98382b0bb1e0a2a5011964e1d9fe465b7b8d1559", 38},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gz bytes.Buffer
+			hash, err := hashGzip(&gz, strings.NewReader(tt.in), tt.level)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hash != tt.hash {
+				t.Errorf("hash = %s, want %s", hash, tt.hash)
+			}
+			if gz.Len() != tt.size {
+				t.Errorf("gzipped size = %d, want %d", gz.Len(), tt.size)
+			}
+			// the hash is of the bytes written, which decompress to the input
+			sum := sha256.Sum256(gz.Bytes())
+			if got := hex.EncodeToString(sum[:]); got != hash {
+				t.Errorf("sha256 of the gzipped bytes = %s, returned %s", got, hash)
+			}
+			zr, err := gzip.NewReader(&gz)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := io.ReadAll(zr)
+			if err != nil {
+				t.Fatal(err)
+			} else if string(b) != tt.in {
+				t.Errorf("decompressed to %q, want %q", b, tt.in)
+			}
+		})
+	}
+}
+
+func TestHashGzipDeterministic(t *testing.T) {
+	in := strings.Repeat("the same contents ", 1000)
+	var hashes []string
+	for i := 0; i < 2; i++ {
+		var gz bytes.Buffer
+		hash, err := hashGzip(&gz, strings.NewReader(in), gzip.DefaultCompression)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashes = append(hashes, hash)
+
+		zr, err := gzip.NewReader(&gz)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the header mustn't record the current time
+		if !zr.ModTime.IsZero() {
+			t.Errorf("gzip header has modification time %v, want none", zr.ModTime)
+		}
+	}
+	if hashes[0] != hashes[1] {
+		t.Errorf("compressing the same contents twice gave different hashes %s and %s", hashes[0], hashes[1])
+	}
+}
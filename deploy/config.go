@@ -0,0 +1,286 @@
+package deploy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// readConfig reads the firebase.json at fbConfFile from fsys,
+// the directory containing it.
+// A fbConfFile of - is read from stdin, relative to the working directory.
+func readConfig(fsys fs.FS, fbConfFile string) (*FirebaseJSON, error) {
+	var b []byte
+	var err error
+	if fbConfFile == "-" {
+		b, err = io.ReadAll(os.Stdin)
+		fbConfFile = "stdin"
+	} else {
+		b, err = fs.ReadFile(fsys, filepath.Base(fbConfFile))
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("config file %s does not exist", fbConfFile)
+	} else if err != nil {
+		return nil, fmt.Errorf("read %s: %w", fbConfFile, err)
+	}
+	b, err = expandEnv(b, os.LookupEnv)
+	if err != nil {
+		return nil, fmt.Errorf("expand %s: %w", fbConfFile, err)
+	}
+	var fbConf FirebaseJSON
+	err = json.Unmarshal(b, &fbConf)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal %s: %w", fbConfFile, err)
+	}
+	for _, h := range fbConf.Hosting {
+		// public is relative to the config file, not the working directory
+		if p := h.Public; p != "" && !filepath.IsAbs(p) {
+			h.Public = filepath.Join(filepath.Dir(fbConfFile), h.Public)
+		}
+	}
+	return &fbConf, nil
+}
+
+// envRef matches ${VAR} and ${VAR:-default}.
+var envRef = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
+
+// expandEnv replaces references to environment variables in the string values of a json document.
+// A default is used if the variable is unset or empty,
+// otherwise unset variables are an error.
+func expandEnv(b []byte, lookup func(string) (string, bool)) ([]byte, error) {
+	if !envRef.Match(b) {
+		return b, nil
+	}
+	var doc any
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	err := dec.Decode(&doc)
+	if err != nil {
+		return nil, err
+	}
+	var missing []string
+	var expand func(v any) any
+	expand = func(v any) any {
+		switch v := v.(type) {
+		case string:
+			return envRef.ReplaceAllStringFunc(v, func(ref string) string {
+				m := envRef.FindStringSubmatch(ref)
+				val, ok := lookup(m[1])
+				if val == "" && strings.Contains(ref, ":-") {
+					return m[2]
+				} else if !ok {
+					missing = append(missing, m[1])
+				}
+				return val
+			})
+		case []any:
+			for i := range v {
+				v[i] = expand(v[i])
+			}
+		case map[string]any:
+			for k := range v {
+				v[k] = expand(v[k])
+			}
+		}
+		return v
+	}
+	doc = expand(doc)
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("unset environment variables: %s", strings.Join(missing, ", "))
+	}
+	return json.Marshal(doc)
+}
+
+// selectHosting returns the hosting configs to deploy.
+// With a single config, site overrides the configured site or target,
+// with multiple configs, site only selects the matching ones.
+// Target always selects configs by their target.
+// Public overrides the public directory, and requires a single config.
+// Targets are resolved to sites using the .firebaserc next to fbConfFile in fsys,
+// for project or its default project.
+func selectHosting(fsys fs.FS, fbConfFile, project string, fbConf *FirebaseJSON, site, target, public string) ([]*Hosting, error) {
+	hostings := fbConf.Hosting
+	if len(hostings) == 0 {
+		return nil, errors.New("no hosting config in " + fbConfFile)
+	}
+	if target != "" {
+		var selected []*Hosting
+		for _, h := range hostings {
+			if h.Target == target {
+				selected = append(selected, h)
+			}
+		}
+		if len(selected) == 0 {
+			return nil, fmt.Errorf("no hosting config for target %s", target)
+		}
+		hostings = selected
+	}
+	if len(hostings) == 1 && site != "" {
+		// an explicit site takes precedence over both site and target in the config
+		hostings[0].Site = site
+		hostings[0].Target = ""
+	}
+	if public != "" {
+		if len(hostings) > 1 {
+			return nil, errors.New("a public directory override needs a single hosting config, select one with a site or target")
+		}
+		hostings[0].Public = public
+	}
+
+	for _, h := range hostings {
+		err := resolveTarget(fsys, fbConfFile, project, h)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(hostings) > 1 && site != "" {
+		var selected []*Hosting
+		for _, h := range hostings {
+			if h.Site == site {
+				selected = append(selected, h)
+			}
+		}
+		if len(selected) == 0 {
+			return nil, fmt.Errorf("no hosting config for site %s", site)
+		}
+		hostings = selected
+	}
+	return hostings, nil
+}
+
+// validateConfig checks for config errors that would otherwise only be
+// reported after a version has been created.
+// The public directories are only checked if checkPublic is set.
+// Rules that duplicate the source of an earlier rule are errors if strict is set,
+// otherwise they're only logged.
+func validateConfig(hostings []*Hosting, checkPublic, strict bool) error {
+	var errs []error
+	for i, h := range hostings {
+		field := "hosting"
+		if len(hostings) > 1 {
+			field = fmt.Sprintf("hosting[%d]", i)
+		}
+		errs = append(errs, h.validate(field, checkPublic)...)
+		for _, dup := range h.duplicateRules(field) {
+			if strict {
+				errs = append(errs, errors.New(dup))
+			} else {
+				slog.Warn("duplicate rule, only one will apply", "rule", dup)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid config: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func (h *Hosting) validate(field string, checkPublic bool) []error {
+	var errs []error
+	if checkPublic {
+		if h.Public == "" {
+			errs = append(errs, fmt.Errorf("%s.public is not set", field))
+		} else if fi, err := os.Stat(h.Public); err != nil {
+			errs = append(errs, fmt.Errorf("%s.public: %w", field, err))
+		} else if !fi.IsDir() {
+			errs = append(errs, fmt.Errorf("%s.public: %s is not a directory", field, h.Public))
+		}
+	}
+	if h.Site == "" && h.Target == "" {
+		errs = append(errs, fmt.Errorf("one of %s.site or %s.target must be set", field, field))
+	}
+	for i, header := range h.Headers {
+		errs = append(errs, validatePattern(fmt.Sprintf("%s.headers[%d]", field, i), header.Source, header.Regex)...)
+	}
+	for i, redirect := range h.Redirects {
+		errs = append(errs, validatePattern(fmt.Sprintf("%s.redirects[%d]", field, i), redirect.Source, redirect.Regex)...)
+		if redirect.Type != 0 && !redirectTypes[redirect.Type] {
+			errs = append(errs, fmt.Errorf("%s.redirects[%d]: type %d for %s is not a redirect, use one of 301, 302, 303, 307, or 308", field, i, redirect.Type, rulePattern(redirect.Source, redirect.Regex)))
+		}
+	}
+	for i, rewrite := range h.Rewrites {
+		f := fmt.Sprintf("%s.rewrites[%d]", field, i)
+		errs = append(errs, validatePattern(f, rewrite.Source, rewrite.Regex)...)
+		errs = append(errs, rewrite.validate(f)...)
+	}
+	return errs
+}
+
+// redirectTypes are the status codes firebase hosting accepts for redirects.
+var redirectTypes = map[int]bool{
+	http.StatusMovedPermanently:  true,
+	http.StatusFound:             true,
+	http.StatusSeeOther:          true,
+	http.StatusTemporaryRedirect: true,
+	http.StatusPermanentRedirect: true,
+}
+
+// validatePattern checks a rule matches by exactly one of a glob source or a regex.
+// Firebase hosting uses RE2 syntax, the same as package regexp.
+func validatePattern(field, source, regex string) []error {
+	switch {
+	case source != "" && regex != "":
+		return []error{fmt.Errorf("%s: only one of source or regex can be set", field)}
+	case source == "" && regex == "":
+		return []error{fmt.Errorf("%s: one of source or regex must be set", field)}
+	case regex != "":
+		_, err := regexp.Compile(regex)
+		if err != nil {
+			return []error{fmt.Errorf("%s.regex: %w", field, err)}
+		}
+	}
+	return nil
+}
+
+type FirebaseJSON struct {
+	Hosting HostingConfigs `json:"hosting,omitempty"`
+}
+
+// HostingConfigs is either a single hosting config, or an array of them.
+type HostingConfigs []*Hosting
+
+func (h *HostingConfigs) UnmarshalJSON(b []byte) error {
+	if b = bytes.TrimSpace(b); len(b) > 0 && b[0] == '[' {
+		var hostings []*Hosting
+		err := json.Unmarshal(b, &hostings)
+		if err != nil {
+			return err
+		}
+		*h = hostings
+		return nil
+	}
+	var hosting Hosting
+	err := json.Unmarshal(b, &hosting)
+	if err != nil {
+		return err
+	}
+	*h = HostingConfigs{&hosting}
+	return nil
+}
+
+type Hosting struct {
+	Site           string         `json:"site,omitempty"`
+	Target         string         `json:"target,omitempty"`
+	Public         string         `json:"public,omitempty"`
+	Ignore         []string       `json:"ignore,omitempty"`
+	Include        []string       `json:"include,omitempty"`
+	CleanURLs      *bool          `json:"cleanUrls,omitempty"`
+	TrailingSlash  *bool          `json:"trailingSlash,omitempty"`
+	Headers        []HeaderRule   `json:"headers,omitempty"`
+	Redirects      []RedirectRule `json:"redirects,omitempty"`
+	Rewrites       []RewriteRule  `json:"rewrites,omitempty"`
+	AppAssociation string         `json:"appAssociation,omitempty"`
+	I18n           *struct {
+		Root string `json:"root,omitempty"`
+	} `json:"i18n,omitempty"`
+}
@@ -0,0 +1,62 @@
+package deploy
+
+import (
+	"log/slog"
+	"mime"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// warnContentTypes warns about files whose content type can't be detected
+// from their extension, unless a header rule sets their Content-Type,
+// as firebase hosting may not serve them with the intended type.
+// Each extension is reported once.
+func warnContentTypes(site string, h *Hosting, pathToHash map[string]string) {
+	var rules []func(p string) bool
+	for _, header := range h.Headers {
+		var setsType bool
+		for _, hdr := range header.Headers {
+			setsType = setsType || strings.EqualFold(hdr.Key, "Content-Type")
+		}
+		if !setsType {
+			continue
+		}
+		if header.Regex != "" {
+			re, err := regexp.Compile(header.Regex)
+			if err != nil {
+				continue
+			}
+			rules = append(rules, re.MatchString)
+		} else if ig, err := newIgnorer([]string{header.Source}); err == nil {
+			rules = append(rules, func(p string) bool { return ig.match(strings.TrimPrefix(p, "/"), false) })
+		}
+	}
+
+	unknown := make(map[string][]string)
+files:
+	for p := range pathToHash {
+		ext := path.Ext(p)
+		if ext != "" && mime.TypeByExtension(ext) != "" {
+			continue
+		}
+		for _, rule := range rules {
+			if rule(p) {
+				continue files
+			}
+		}
+		unknown[ext] = append(unknown[ext], p)
+	}
+
+	exts := make([]string, 0, len(unknown))
+	for ext := range unknown {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+	for _, ext := range exts {
+		paths := unknown[ext]
+		sort.Strings(paths)
+		slog.Warn("unknown content type, add a header rule to set it", "site", site, "extension", ext, "files", len(paths), "example", paths[0])
+	}
+}
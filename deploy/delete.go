@@ -0,0 +1,55 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+// DeleteVersion deletes a version of site,
+// either a full sites/SITE/versions/VERSION name or just the VERSION id.
+// The version released to the live channel is only deleted with force,
+// as the site stops serving once it's gone.
+func (d *Deployer) DeleteVersion(ctx context.Context, o Options, site, version string, force bool) error {
+	o = o.withDefaults()
+	_, client, _, err := d.clients(ctx, o)
+	if err != nil {
+		return err
+	}
+	parent := "sites/" + site
+	version, err = versionName(parent, version)
+	if err != nil {
+		return err
+	}
+
+	live, err := liveVersion(ctx, client, parent)
+	if err != nil {
+		return classify(err)
+	}
+	isLive := live != nil && live.Name == version
+	if isLive {
+		if !force {
+			return withClass(ErrConfig, fmt.Errorf("version %s is released to the live channel of %s, deleting it takes the site down (use -force to delete it anyway)", version, parent))
+		}
+		slog.Warn("DELETING THE VERSION RELEASED TO LIVE, the site stops serving until another version is released", "version", version, "site", parent)
+	}
+
+	if o.Confirm != nil {
+		prompt := fmt.Sprintf("delete %s?", version)
+		if isLive {
+			prompt = fmt.Sprintf("delete %s, released to live, taking %s down?", version, parent)
+		}
+		ok, err := o.Confirm(prompt)
+		if err != nil {
+			return err
+		} else if !ok {
+			return errors.New("delete cancelled")
+		}
+	}
+	err = client.DeleteVersion(ctx, version)
+	if err != nil {
+		return classify(fmt.Errorf("delete version %s: %w", version, err))
+	}
+	return nil
+}
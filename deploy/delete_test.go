@@ -0,0 +1,33 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDeleteLiveVersion(t *testing.T) {
+	f := newFakeAPI(t)
+	config := writeSite(t, map[string]string{"index.html": "<h1>hello</h1>"})
+	res, err := f.deployer().Deploy(context.Background(), Options{Config: config})
+	if err != nil {
+		t.Fatal(err)
+	}
+	live := res.Sites[0].Version
+
+	d := f.deployer()
+	err = d.DeleteVersion(context.Background(), Options{Config: config}, "test", live, false)
+	if !errors.Is(err, ErrConfig) || !strings.Contains(err.Error(), "-force") {
+		t.Errorf("deleting the live version = %v, want an ErrConfig naming -force", err)
+	} else if _, ok := f.versions[live]; !ok {
+		t.Fatal("live version deleted without force")
+	}
+
+	err = d.DeleteVersion(context.Background(), Options{Config: config}, "test", live, true)
+	if err != nil {
+		t.Fatal(err)
+	} else if _, ok := f.versions[live]; ok {
+		t.Error("live version wasn't deleted with force")
+	}
+}
@@ -0,0 +1,962 @@
+// Package deploy uploads a directory of files to firebase hosting
+// as a new version, then releases it.
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	firebasehosting "google.golang.org/api/firebasehosting/v1beta1"
+	"google.golang.org/api/googleapi"
+)
+
+// Default values used for unset Options.
+const (
+	DefaultConcurrency = 8
+	DefaultRetries     = 5
+	// DefaultMaxFiles limits the files in a version,
+	// firebase hosting rejects versions with too many files,
+	// but only after they've been uploaded.
+	DefaultMaxFiles = 100_000
+	// DefaultUserAgent identifies requests from the library.
+	DefaultUserAgent = "fbhuploader"
+)
+
+// Options configures a deploy.
+type Options struct {
+	// Config is the path to firebase.json,
+	// or - to read it from stdin, with paths relative to the working directory.
+	Config string
+	// Site overrides the site of a single hosting config,
+	// or selects one of multiple hosting configs.
+	Site string
+	// Target only deploys the hosting config for this target.
+	Target string
+	// Public overrides the directory of files to deploy.
+	Public string
+	// ExtraPublic are more directories of files deployed together with Public,
+	// to a single hosting config.
+	// A path in more than one directory must have the same contents in each.
+	ExtraPublic []string
+	// FailFast stops after the first failed deploy of multiple hosting configs.
+	FailFast bool
+
+	// Project is the firebase project the sites belong to,
+	// it is checked before deploying and used to resolve targets.
+	// If empty, the default project from .firebaserc is used for targets.
+	Project string
+	// Credentials is the path to a service account key file,
+	// application default credentials are used if empty.
+	Credentials string
+	// CredentialsJSON is the contents of a service account key file,
+	// used instead of Credentials, so secrets don't need to be written to disk.
+	CredentialsJSON []byte
+	// Concurrency is the maximum number of files to upload in parallel,
+	// it is reduced while uploads are rate limited.
+	Concurrency int
+	// Retries is the maximum attempts for each file upload.
+	Retries int
+	// UploadTimeout limits each attempt at uploading a file, zero for no limit.
+	UploadTimeout time.Duration
+	// ReadConcurrency is the number of files compressed in parallel,
+	// GOMAXPROCS if not positive.
+	ReadConcurrency int
+	// UsePrecompressed uploads the bytes of a FILE.gz, if it exists, as the contents of FILE
+	// instead of compressing FILE, and doesn't deploy FILE.gz itself.
+	UsePrecompressed bool
+	// Compression is the gzip level: default, speed, best, or 0-9.
+	// Empty uses the default.
+	Compression string
+
+	// DryRun reports the files that would be uploaded without deploying.
+	DryRun bool
+	// Diff compares the files to the live version without deploying.
+	Diff bool
+	// MaxFiles is the most files a version can have,
+	// 0 uses DefaultMaxFiles, negative disables the limit.
+	MaxFiles int
+	// MaxFileSize, if positive, skips files larger than this many bytes.
+	MaxFileSize int64
+	// ModifiedAfter, if set, skips files last modified before it.
+	ModifiedAfter time.Time
+	// Extensions, if set, are the only file extensions deployed, like html or .css,
+	// ignoring case.
+	Extensions []string
+	// AllowEmpty allows deploying a version with no files.
+	AllowEmpty bool
+	// Strict fails on config mistakes that are otherwise only warned about,
+	// such as header or redirect rules for the same source.
+	Strict bool
+	// CheckIgnore warns about files served by the live version
+	// that are no longer deployed because they're ignored,
+	// StrictIgnore fails the deploy instead.
+	CheckIgnore  bool
+	StrictIgnore bool
+	// Include only deploys the paths matching these globs,
+	// in addition to the hosting config's include list.
+	Include []string
+	// Archive deploys the files in this .zip, .tar, .tar.gz, or .tgz file
+	// instead of the public directory, for a single site.
+	Archive string
+	// Only deploys these files or directories under public,
+	// keeping the rest of the live version.
+	Only []string
+	// MergeLive keeps the files of the live version that don't exist locally,
+	// so public only needs to contain new and changed files.
+	MergeLive bool
+	// NoLock skips checking for other deploys to the same site in progress.
+	NoLock bool
+	// KeepFailed keeps the created version if the deploy fails,
+	// labelled so it isn't mistaken for a deploy in progress.
+	KeepFailed bool
+	// Resume continues with the newest unfinished version left by an earlier deploy,
+	// only uploading the files it's still missing, instead of creating a new one.
+	// Failed versions are kept to be resumed,
+	// those of crashed deploys are only resumed once they're an hour old,
+	// as until then they may belong to another deploy in progress.
+	Resume bool
+	// Verify checks file contents match their hashes before uploading.
+	Verify bool
+	// NoDefaultIgnores disables the default ignore patterns:
+	// firebase.json, **/.*, and **/node_modules/**.
+	NoDefaultIgnores bool
+	// FollowSymlinks descends into symlinked directories,
+	// symlinked files are always deployed with their target's content.
+	FollowSymlinks bool
+	// NoCache recomputes all file hashes instead of using the hash cache.
+	NoCache bool
+
+	// SkipUnchanged doesn't deploy to live if the live version
+	// already has the same files and config.
+	// Only versions from earlier deploys can be compared,
+	// by the hashes of their config and files recorded in their labels.
+	// Versions are still created with NoRelease, e.g. to keep a record.
+	SkipUnchanged bool
+	// NoRelease stops after finalizing the version,
+	// leaving it to be released later, e.g. with Rollback.
+	NoRelease bool
+	// HeadersFile and RedirectsFile are json arrays of rules
+	// in the same format as firebase.json,
+	// merged into each hosting config after its own rules.
+	HeadersFile   string
+	RedirectsFile string
+	// CacheControl sets the Cache-Control header for paths matching a glob,
+	// given as GLOB=VALUE, merged after HeadersFile.
+	CacheControl []string
+
+	// Channel deploys to this preview channel instead of live.
+	Channel string
+	// ChannelExpires is the time until the preview channel expires,
+	// the server default is used if zero.
+	ChannelExpires time.Duration
+	// ReleaseType is recorded in the release history: DEPLOY or ROLLBACK.
+	// Deploy defaults to DEPLOY and Rollback to ROLLBACK.
+	ReleaseType string
+	// Message is the release message,
+	// the git commit of the config directory is used if empty.
+	Message string
+	// KeepVersions, if positive, deletes the oldest finalized versions
+	// after releasing to live, keeping this many besides those released to any channel.
+	KeepVersions int
+	// Wait is how long to wait after releasing for the channel to serve the new version.
+	Wait time.Duration
+
+	// Webhook is sent a json description of each release, as a POST.
+	// Failed calls are only logged unless WebhookRequired is set.
+	Webhook         string
+	WebhookRequired bool
+
+	// Confirm is asked before releasing to the live channel,
+	// the release is cancelled if it returns false.
+	// If nil, releases without asking.
+	Confirm func(question string) (bool, error)
+	// Progress receives upload progress, nil disables it.
+	Progress io.Writer
+
+	// UserAgent identifies api, upload, and webhook requests, DefaultUserAgent if empty.
+	UserAgent string
+	// Proxy is the url of a proxy for api, upload, and webhook requests,
+	// overriding the HTTPS_PROXY and NO_PROXY environment variables.
+	Proxy string
+
+	// BaseURL replaces the firebase hosting api endpoint, e.g. for a test server.
+	// Requests are unauthenticated unless Credentials or CredentialsJSON is also set.
+	BaseURL string
+	// UploadBaseURL replaces the scheme and host of the upload url.
+	UploadBaseURL string
+}
+
+// Deployer deploys to firebase hosting.
+// The zero value creates its clients from Options.Credentials.
+type Deployer struct {
+	// HTTPClient is used to upload files, and API for everything else,
+	// both must be set to be used.
+	HTTPClient *http.Client
+	API        API
+	// Project is the firebase project id, used for console links.
+	Project string
+}
+
+// Deploy deploys each of the selected hosting configs.
+// Failed deploys are reported both in the result and in the returned error,
+// which matches one of ErrConfig, ErrAuth, ErrTransient, or ErrRejected if the cause is known.
+func (d *Deployer) Deploy(ctx context.Context, o Options) (*Result, error) {
+	o = o.withDefaults()
+	level, err := parseCompression(o.Compression)
+	if err != nil {
+		return nil, withClass(ErrConfig, err)
+	}
+	if o.ReleaseType == "" {
+		o.ReleaseType = "DEPLOY"
+	}
+	err = checkReleaseType(o.ReleaseType)
+	if err != nil {
+		return nil, withClass(ErrConfig, err)
+	}
+	hostings, err := o.hostings()
+	if err != nil {
+		return nil, err
+	}
+	if o.Archive != "" && len(hostings) > 1 {
+		return nil, withClass(ErrConfig, fmt.Errorf("-archive can only be deployed to a single site, select one of %d with -site", len(hostings)))
+	}
+	err = validateConfig(hostings, o.Archive == "", o.Strict)
+	if err != nil {
+		return nil, withClass(ErrConfig, err)
+	}
+	if len(o.ExtraPublic) > 0 {
+		err = checkExtraPublic(o, hostings)
+		if err != nil {
+			return nil, withClass(ErrConfig, err)
+		}
+	}
+	httpClient, client, project, err := d.clients(ctx, o)
+	if err != nil {
+		return nil, err
+	}
+	var hookClient *http.Client
+	if o.Webhook != "" {
+		hookClient, err = webhookClient(o.UserAgent, o.Proxy)
+		if err != nil {
+			return nil, withClass(ErrConfig, err)
+		}
+	}
+
+	res := &Result{}
+	var errs []error
+	for _, h := range hostings {
+		start := time.Now()
+		sr, err := deploySite(ctx, o, httpClient, client, project, level, h)
+		if err != nil {
+			err = classify(fmt.Errorf("deploy sites/%s: %w", h.Site, err))
+			errs = append(errs, err)
+			res.Sites = append(res.Sites, &SiteResult{
+				Site:  "sites/" + h.Site,
+				Error: err.Error(),
+			})
+			if o.FailFast {
+				break
+			}
+			continue
+		}
+		sr.Elapsed = time.Since(start)
+		if o.Webhook != "" && sr.Release != "" {
+			err = callWebhook(ctx, hookClient, o.Webhook, o.Channel, sr)
+			if err != nil && o.WebhookRequired {
+				err = fmt.Errorf("deploy sites/%s: released %s, but %w", h.Site, sr.Release, err)
+				errs = append(errs, err)
+				sr.Error = err.Error()
+			} else if err != nil {
+				slog.Warn("webhook failed", "site", sr.Site, "err", err)
+			}
+		}
+		res.Sites = append(res.Sites, sr)
+	}
+	return res, errors.Join(errs...)
+}
+
+// withDefaults fills in unset options.
+func (o Options) withDefaults() Options {
+	if o.Config == "" {
+		o.Config = "firebase.json"
+	}
+	if o.Concurrency <= 0 {
+		o.Concurrency = DefaultConcurrency
+	}
+	if o.Retries <= 0 {
+		o.Retries = DefaultRetries
+	}
+	if o.ReadConcurrency <= 0 {
+		o.ReadConcurrency = runtime.GOMAXPROCS(0)
+	}
+	if o.Compression == "" {
+		o.Compression = "default"
+	}
+	if o.UserAgent == "" {
+		o.UserAgent = DefaultUserAgent
+	}
+	if o.MaxFiles == 0 {
+		o.MaxFiles = DefaultMaxFiles
+	}
+	return o
+}
+
+// hostings reads the config and returns the hosting configs selected by o,
+// with their targets resolved to sites.
+func (o Options) hostings() ([]*Hosting, error) {
+	confFS := os.DirFS(filepath.Dir(o.Config))
+	fbConf, err := readConfig(confFS, o.Config)
+	if err != nil {
+		return nil, withClass(ErrConfig, err)
+	}
+	hostings, err := selectHosting(confFS, o.Config, o.Project, fbConf, o.Site, o.Target, o.Public)
+	if err != nil {
+		return nil, withClass(ErrConfig, err)
+	}
+	for _, h := range hostings {
+		h.Include = append(h.Include, o.Include...)
+	}
+	if o.HeadersFile != "" {
+		headers, err := readRules[HeaderRule](o.HeadersFile)
+		if err != nil {
+			return nil, withClass(ErrConfig, err)
+		}
+		for _, h := range hostings {
+			h.mergeHeaders(headers)
+		}
+	}
+	if len(o.CacheControl) > 0 {
+		rules, err := cacheRules(o.CacheControl)
+		if err != nil {
+			return nil, withClass(ErrConfig, err)
+		}
+		for _, h := range hostings {
+			h.mergeHeaders(rules)
+		}
+	}
+	if o.RedirectsFile != "" {
+		redirects, err := readRules[RedirectRule](o.RedirectsFile)
+		if err != nil {
+			return nil, withClass(ErrConfig, err)
+		}
+		for _, h := range hostings {
+			h.mergeRedirects(redirects)
+		}
+	}
+	return hostings, nil
+}
+
+// clients returns the Deployer's clients,
+// or creates them from the credentials in o.
+func (d *Deployer) clients(ctx context.Context, o Options) (*http.Client, API, string, error) {
+	httpClient, client, project := d.HTTPClient, d.API, d.Project
+	if httpClient == nil || client == nil {
+		var err error
+		httpClient, client, project, err = newClients(ctx, o.Credentials, o.CredentialsJSON, o.Project, o.BaseURL, o.UserAgent, o.Proxy, o.Concurrency)
+		if err != nil {
+			return nil, nil, "", withClass(ErrAuth, err)
+		}
+	}
+	if o.Project != "" {
+		project = o.Project
+	}
+	return httpClient, client, project, nil
+}
+
+// deploySite deploys a single hosting config.
+func deploySite(ctx context.Context, o Options, httpClient *http.Client, client API, project string, level int, h *Hosting) (res *SiteResult, err error) {
+	site := "sites/" + h.Site
+	slog.Info("deploying", "config", o.Config, "site", site, "public", h.Public)
+	if o.Project != "" {
+		err = checkSite(ctx, client, o.Project, h.Site)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = preflight(ctx, client, site)
+	if err != nil {
+		return nil, err
+	}
+
+	roots := []publicRoot{{name: h.Public, fsys: os.DirFS(h.Public)}}
+	if o.Archive != "" {
+		fsys, closeArchive, err := openArchive(o.Archive)
+		if err != nil {
+			return nil, withClass(ErrConfig, err)
+		}
+		defer closeArchive()
+		roots = []publicRoot{{name: o.Archive, fsys: fsys, archive: true}}
+	}
+	for _, p := range o.ExtraPublic {
+		roots = append(roots, publicRoot{name: p, fsys: os.DirFS(p)})
+	}
+	fr := &fileReader{
+		spool:            &spool{},
+		level:            level,
+		maxFiles:         o.MaxFiles,
+		concurrency:      o.ReadConcurrency,
+		usePrecompressed: o.UsePrecompressed,
+		maxFileSize:      o.MaxFileSize,
+		modifiedAfter:    o.ModifiedAfter,
+		extensions:       extensionSet(o.Extensions),
+		configFS:         os.DirFS(filepath.Dir(o.Config)),
+		followSymlinks:   o.FollowSymlinks,
+		noDefaultIgnores: o.NoDefaultIgnores,
+	}
+	defer fr.spool.cleanup()
+	sel := newSelection(o.Only)
+	pathToHash, hashToContent, err := readRoots(ctx, fr, o, h, sel, roots)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("read files", "files", len(pathToHash), "contents", len(hashToContent))
+	warnContentTypes(site, h, pathToHash)
+	if (o.CheckIgnore || o.StrictIgnore) && len(sel) == 0 && !o.MergeLive {
+		live, err := liveFiles(ctx, client, site)
+		if err != nil {
+			return nil, err
+		}
+		if removed := ignoredLive(live, pathToHash, fr.ignored); len(removed) > 0 {
+			if o.StrictIgnore {
+				return nil, withClass(ErrConfig, fmt.Errorf("ignored files are served by the live version of %s and would be removed: %s", site, strings.Join(removed, ", ")))
+			}
+			slog.Warn("ignored files are served by the live version and will be removed", "site", site, "paths", removed)
+		}
+	}
+	if len(sel) > 0 || o.MergeLive {
+		err = mergeLive(ctx, client, site, pathToHash, sel)
+		if err != nil {
+			return nil, err
+		}
+		slog.Info("merged live version", "files", len(pathToHash))
+		if o.MaxFiles > 0 && len(pathToHash) > o.MaxFiles {
+			return nil, withClass(ErrConfig, tooManyFiles(o.MaxFiles))
+		}
+	}
+	if len(pathToHash) == 0 && !o.AllowEmpty {
+		return nil, withClass(ErrConfig, fmt.Errorf("no files to deploy in %s, releasing would empty the site (use -allow-empty if intended)", h.Public))
+	}
+
+	if o.Diff {
+		live, err := liveFiles(ctx, client, site)
+		if err != nil {
+			return nil, err
+		}
+		return &SiteResult{
+			DryRun:          true,
+			Site:            site,
+			RawBytes:        fr.rawBytes,
+			CompressedBytes: fr.gzBytes,
+			Diff:            diffFiles(live, pathToHash),
+		}, nil
+	}
+
+	if o.DryRun {
+		toUpload, err := dryRun(ctx, client, site, pathToHash)
+		if err != nil {
+			return nil, err
+		}
+		res := &SiteResult{
+			DryRun:          true,
+			Site:            site,
+			Uploaded:        len(toUpload),
+			Skipped:         len(pathToHash) - len(toUpload),
+			RawBytes:        fr.rawBytes,
+			CompressedBytes: fr.gzBytes,
+			Files:           toUpload,
+			Manifest:        make(map[string]ManifestFile, len(pathToHash)),
+		}
+		upload := make(map[string]bool, len(toUpload))
+		for _, p := range toUpload {
+			upload[p] = true
+		}
+		for p, hash := range pathToHash {
+			res.Manifest[p] = ManifestFile{Hash: hash, Uploaded: upload[p]}
+		}
+		return res, nil
+	}
+
+	if o.SkipUnchanged && !o.NoRelease && o.Channel == "" {
+		same, err := unchanged(ctx, client, site, h, pathToHash)
+		if err != nil {
+			return nil, err
+		} else if same {
+			slog.Info("no changes, nothing to deploy", "site", site)
+			return &SiteResult{
+				Unchanged:       true,
+				Site:            site,
+				Skipped:         len(pathToHash),
+				RawBytes:        fr.rawBytes,
+				CompressedBytes: fr.gzBytes,
+			}, nil
+		}
+	}
+
+	var version string
+	if o.Resume {
+		version, err = resumeVersion(ctx, client, site, h, pathToHash)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if version == "" {
+		version, err = createVersion(ctx, client, site, h, pathToHash)
+		if err != nil {
+			return nil, err
+		}
+		slog.Info("created version", "version", version)
+	}
+	var released bool
+	defer func() {
+		if err == nil || released {
+			return
+		} else if o.KeepFailed || o.Resume {
+			keepVersion(client, version, h, pathToHash)
+		} else {
+			deleteVersion(client, version)
+		}
+	}()
+
+	if !o.NoLock {
+		err = checkLock(ctx, client, site, version)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	toUpload, uploadURL, err := getRequiredUploads(ctx, client, version, pathToHash, o.UploadBaseURL)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("populated files", "version", version, "required_uploads", len(toUpload))
+
+	u := &uploader{
+		httpClient:  httpClient,
+		uploadURL:   uploadURL,
+		concurrency: o.Concurrency,
+		attempts:    o.Retries,
+		timeout:     o.UploadTimeout,
+		progress:    o.Progress,
+		verify:      o.Verify,
+		refresh: func(ctx context.Context) ([]string, string, error) {
+			return getRequiredUploads(ctx, client, version, pathToHash, o.UploadBaseURL)
+		},
+	}
+	err = uploadFiles(ctx, client, u, version, toUpload, hashToContent)
+	if err != nil {
+		return nil, err
+	}
+
+	res = &SiteResult{
+		Site:            site,
+		Version:         version,
+		DashboardURL:    dashboardURL(project, h.Site),
+		RawBytes:        fr.rawBytes,
+		CompressedBytes: fr.gzBytes,
+		UploadedHashes:  slices.Clone(toUpload),
+	}
+	slices.Sort(res.UploadedHashes)
+	uploaded := make(map[string]bool, len(toUpload))
+	for _, hash := range toUpload {
+		uploaded[hash] = true
+		if c, ok := hashToContent[hash]; ok {
+			res.Bytes += c.size
+		}
+	}
+	res.Manifest = make(map[string]ManifestFile, len(pathToHash))
+	for p, hash := range pathToHash {
+		if uploaded[hash] {
+			res.Uploaded++
+		} else {
+			res.Skipped++
+		}
+		res.Manifest[p] = ManifestFile{Hash: hash, Uploaded: uploaded[hash]}
+	}
+
+	if o.NoRelease {
+		// finalized versions are kept for a later release
+		released = true
+		slog.Info("not releasing", "version", version)
+		return res, nil
+	}
+
+	message := o.Message
+	if message == "" {
+		message = gitCommit(filepath.Dir(o.Config))
+	}
+
+	if o.Channel != "" {
+		rel, url, err := releaseChannel(ctx, client, site, o.Channel, o.ChannelExpires, version, message, o.ReleaseType)
+		if err != nil {
+			return nil, err
+		}
+		released = true
+		res.Release = rel.Name
+		res.PreviewURL = url
+		slog.Info("released to channel", "release", rel.Name, "url", url)
+		if o.Wait > 0 {
+			err = waitForRelease(ctx, client, site+"/channels/"+o.Channel, version, o.Wait)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return res, nil
+	}
+
+	if o.Confirm != nil {
+		ok, err := o.Confirm(fmt.Sprintf("release %s to the live channel of %s?", version, site))
+		if err != nil {
+			return nil, err
+		} else if !ok {
+			return nil, errors.New("release cancelled")
+		}
+	}
+
+	rel, err := release(ctx, client, site, version, message, o.ReleaseType)
+	if err != nil {
+		return nil, err
+	}
+	released = true
+	res.Release = rel.Name
+	res.SiteURL = "https://" + h.Site + ".web.app"
+	slog.Info("released", "release", rel.Name)
+	if o.Wait > 0 {
+		err = waitForRelease(ctx, client, site+"/channels/live", version, o.Wait)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if o.KeepVersions > 0 {
+		// versions serving preview channels are kept too,
+		// along with the new one in case the listing is stale
+		releasedSet, err := releasedVersions(ctx, client, site)
+		if err == nil {
+			err = pruneVersions(ctx, client, site, o.KeepVersions, append(releasedSet, version)...)
+		}
+		if err != nil {
+			// the deploy itself succeeded
+			slog.Warn("prune old versions", "site", site, "err", err)
+		}
+	}
+
+	return res, nil
+}
+
+// checkSite verifies site belongs to project,
+// a clearer error than failing to create a version.
+func checkSite(ctx context.Context, client API, project, site string) error {
+	_, err := client.GetSite(ctx, "projects/"+project+"/sites/"+site)
+	var gerr *googleapi.Error
+	if errors.As(err, &gerr) && gerr.Code == http.StatusNotFound {
+		return withClass(ErrConfig, fmt.Errorf("site %s not found in project %s", site, project))
+	} else if err != nil {
+		return fmt.Errorf("get site %s in project %s: %w", site, project, err)
+	}
+	return nil
+}
+
+// publicRoot is a directory or archive of files to deploy.
+type publicRoot struct {
+	name string
+	fsys fs.FS
+	// archive entries often share a fixed modification time,
+	// so cached hashes can't be trusted
+	archive bool
+}
+
+// readRoots reads the files of each root, each with its own hash cache,
+// and merges them into a single mapping of paths to hashes.
+func readRoots(ctx context.Context, fr *fileReader, o Options, h *Hosting, sel selection, roots []publicRoot) (map[string]string, map[string]*fileContent, error) {
+	pathToHash := make(map[string]string)
+	hashToContent := make(map[string]*fileContent)
+	pathToRoot := make(map[string]string)
+	for _, root := range roots {
+		cache, err := loadCache(filepath.Dir(o.Config), root.name, o.NoCache || root.archive)
+		if err != nil {
+			return nil, nil, err
+		}
+		fr.cache = cache
+		p2h, h2c, err := fr.readFiles(ctx, root.fsys, h, sel)
+		if err != nil {
+			return nil, nil, withClass(ErrConfig, err)
+		}
+		if !root.archive {
+			if len(sel) == 0 {
+				cache.prune(p2h)
+			}
+			err = cache.save()
+			if err != nil {
+				slog.Warn("save hash cache", "err", err)
+			}
+		}
+
+		for p, hash := range p2h {
+			if prev, ok := pathToHash[p]; ok && prev != hash {
+				return nil, nil, withClass(ErrConfig, fmt.Errorf("%s has different contents in %s and %s", p, pathToRoot[p], root.name))
+			}
+			pathToHash[p], pathToRoot[p] = hash, root.name
+		}
+		for hash, c := range h2c {
+			hashToContent[hash] = c
+		}
+	}
+	if len(roots) > 1 && o.MaxFiles > 0 && len(pathToHash) > o.MaxFiles {
+		return nil, nil, withClass(ErrConfig, tooManyFiles(o.MaxFiles))
+	}
+	return pathToHash, hashToContent, nil
+}
+
+// checkExtraPublic checks the ExtraPublic directories can be deployed with the hostings.
+func checkExtraPublic(o Options, hostings []*Hosting) error {
+	if o.Archive != "" {
+		return errors.New("several -public directories can't be deployed with -archive")
+	} else if len(hostings) > 1 {
+		return fmt.Errorf("several -public directories can only be deployed to a single site, select one of %d with -site", len(hostings))
+	}
+	var errs []error
+	for _, p := range o.ExtraPublic {
+		if fi, err := os.Stat(p); err != nil {
+			errs = append(errs, err)
+		} else if !fi.IsDir() {
+			errs = append(errs, fmt.Errorf("%s is not a directory", p))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// createVersion creates a new version of site with the serving config from h.
+func createVersion(ctx context.Context, client API, site string, h *Hosting, pathToHash map[string]string) (string, error) {
+	conf := servingConfig(h)
+	labels, err := versionLabels(conf, pathToHash)
+	if err != nil {
+		return "", err
+	}
+	version, err := client.CreateVersion(ctx, site, &firebasehosting.Version{
+		Config: conf,
+		Labels: labels,
+	})
+	if err != nil {
+		return "", fmt.Errorf("create new version for %s: %w", site, err)
+	}
+	return version.Name, nil
+}
+
+// resumeVersion finds an unfinished version left by an earlier deploy of site
+// and updates its config from h, returning an empty string if there is none.
+// Files can't be removed from a version,
+// so it isn't resumed if it has paths that are no longer in pathToHash.
+func resumeVersion(ctx context.Context, client API, site string, h *Hosting, pathToHash map[string]string) (string, error) {
+	version, err := findDraft(ctx, client, site)
+	if err != nil || version == "" {
+		return "", err
+	}
+	files, err := versionFiles(ctx, client, version)
+	if err != nil {
+		return "", err
+	}
+	for p := range files {
+		if _, ok := pathToHash[p]; !ok {
+			slog.Warn("not resuming version with removed files", "version", version, "path", p)
+			return "", nil
+		}
+	}
+	conf := servingConfig(h)
+	labels, err := versionLabels(conf, pathToHash)
+	if err != nil {
+		return "", err
+	}
+	_, err = client.PatchVersion(ctx, &firebasehosting.Version{
+		Name:   version,
+		Config: conf,
+		Labels: labels,
+	}, "config,labels")
+	if err != nil {
+		return "", fmt.Errorf("update config of %s: %w", version, err)
+	}
+	slog.Info("resuming version", "version", version)
+	return version, nil
+}
+
+// servingConfig converts the headers, redirects, and other options of h for the api.
+func servingConfig(h *Hosting) *firebasehosting.ServingConfig {
+	servingConf := &firebasehosting.ServingConfig{
+		AppAssociation: h.AppAssociation,
+	}
+	if h.CleanURLs != nil {
+		servingConf.CleanUrls = *h.CleanURLs
+		// an explicit false is different from unset
+		servingConf.ForceSendFields = append(servingConf.ForceSendFields, "CleanUrls")
+	}
+	if h.TrailingSlash != nil {
+		servingConf.TrailingSlashBehavior = "REMOVE"
+		if *h.TrailingSlash {
+			servingConf.TrailingSlashBehavior = "ADD"
+		}
+	}
+	if h.I18n != nil {
+		servingConf.I18n = &firebasehosting.I18nConfig{
+			Root: h.I18n.Root,
+		}
+	}
+	for _, header := range h.Headers {
+		hdrs := make(map[string]string)
+		for _, hdr := range header.Headers {
+			hdrs[hdr.Key] = hdr.Value
+		}
+		servingConf.Headers = append(servingConf.Headers, &firebasehosting.Header{
+			Glob:    header.Source,
+			Regex:   header.Regex,
+			Headers: hdrs,
+		})
+	}
+	for _, redirect := range h.Redirects {
+		code := redirect.Type
+		if code == 0 {
+			// the firebase cli default, the api requires one
+			code = http.StatusMovedPermanently
+		}
+		servingConf.Redirects = append(servingConf.Redirects, &firebasehosting.Redirect{
+			Glob:       redirect.Source,
+			Regex:      redirect.Regex,
+			Location:   redirect.Destination,
+			StatusCode: int64(code),
+		})
+	}
+	for _, rewrite := range h.Rewrites {
+		rw := &firebasehosting.Rewrite{
+			Glob:         rewrite.Source,
+			Regex:        rewrite.Regex,
+			Path:         rewrite.Destination,
+			DynamicLinks: rewrite.DynamicLinks,
+		}
+		if f := rewrite.Function; f != nil {
+			rw.Function, rw.FunctionRegion = f.FunctionID, f.Region
+		}
+		if run := rewrite.Run; run != nil {
+			region := run.Region
+			if region == "" {
+				region = "us-central1"
+			}
+			rw.Run = &firebasehosting.CloudRunRewrite{
+				ServiceId: run.ServiceID,
+				Region:    region,
+			}
+		}
+		servingConf.Rewrites = append(servingConf.Rewrites, rw)
+	}
+	return servingConf
+}
+
+// getRequiredUploads adds the files to version,
+// returning the hashes to upload and the upload url, moved to uploadBaseURL if set.
+func getRequiredUploads(ctx context.Context, client API, version string, pathToHash map[string]string, uploadBaseURL string) ([]string, string, error) {
+	populateResponse, err := client.PopulateFiles(ctx, version, pathToHash)
+	if err != nil {
+		return nil, "", fmt.Errorf("get required uploads for %s: %w", version, err)
+	}
+	// upload each distinct content once, even if requested multiple times
+	seen := make(map[string]bool, len(populateResponse.UploadRequiredHashes))
+	var toUpload []string
+	for _, hash := range populateResponse.UploadRequiredHashes {
+		if !seen[hash] {
+			seen[hash] = true
+			toUpload = append(toUpload, hash)
+		}
+	}
+	uploadURL, err := rebaseURL(populateResponse.UploadUrl, uploadBaseURL)
+	if err != nil {
+		return nil, "", err
+	}
+	return toUpload, uploadURL, nil
+}
+
+// dashboardURL links to the site's hosting dashboard in the firebase console,
+// which lists its versions and releases.
+// An unknown project lets the console pick.
+func dashboardURL(project, site string) string {
+	if project == "" {
+		project = "_"
+	}
+	return "https://console.firebase.google.com/project/" + project + "/hosting/sites/" + site
+}
+
+// gitCommit returns the short commit hash checked out in dir,
+// or an empty string if it isn't in a git repository.
+func gitCommit(dir string) string {
+	cmd := exec.Command("git", "rev-parse", "--short", "HEAD")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// deleteVersion makes a best effort attempt at cleaning up a version
+// left behind by a failed deploy.
+// It uses its own context as the deploy's may already be cancelled.
+func deleteVersion(client API, version string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	err := client.DeleteVersion(ctx, version)
+	if err != nil {
+		slog.Warn("delete failed version", "version", version, "err", err)
+		return
+	}
+	slog.Info("deleted failed version", "version", version)
+}
+
+// keepVersion makes a best effort attempt at labelling a version
+// left behind by a failed deploy as kept,
+// so it doesn't hold the lock for later deploys.
+// It uses its own context as the deploy's may already be cancelled.
+func keepVersion(client API, version string, h *Hosting, pathToHash map[string]string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	labels, err := versionLabels(servingConfig(h), pathToHash)
+	if err != nil {
+		slog.Warn("label failed version", "version", version, "err", err)
+		return
+	}
+	labels[keptLabel] = "true"
+	_, err = client.PatchVersion(ctx, &firebasehosting.Version{
+		Name:   version,
+		Labels: labels,
+	}, "labels")
+	if err != nil {
+		slog.Warn("label failed version", "version", version, "err", err)
+		return
+	}
+	slog.Info("kept failed version", "version", version)
+}
+
+// checkReleaseType rejects release types that don't release a version.
+func checkReleaseType(t string) error {
+	if t != "DEPLOY" && t != "ROLLBACK" {
+		return fmt.Errorf("invalid release type %q, must be DEPLOY or ROLLBACK", t)
+	}
+	return nil
+}
+
+func release(ctx context.Context, client API, site, version, message, releaseType string) (*firebasehosting.Release, error) {
+	rel, err := client.CreateRelease(ctx, site, version, &firebasehosting.Release{
+		Message: message,
+		Type:    releaseType,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("release %s: %w", version, err)
+	}
+	return rel, nil
+}
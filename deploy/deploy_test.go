@@ -0,0 +1,97 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// writeSite writes a firebase.json for site test with files in its public directory,
+// returning the path to the config.
+func writeSite(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for p, content := range files {
+		p = filepath.Join(dir, "public", filepath.FromSlash(p))
+		err := os.MkdirAll(filepath.Dir(p), 0o755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(p, []byte(content), 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	config := filepath.Join(dir, "firebase.json")
+	err := os.WriteFile(config, []byte(`{"hosting": {"site": "test", "public": "public"}}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+// gzipHash returns the hash content is uploaded with.
+func gzipHash(t *testing.T, content string) string {
+	t.Helper()
+	hash, err := hashGzip(&bytes.Buffer{}, bytes.NewReader([]byte(content)), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func TestDeploy(t *testing.T) {
+	f := newFakeAPI(t)
+	config := writeSite(t, map[string]string{
+		"index.html":   "<h1>hello</h1>",
+		"about.html":   "<h1>about</h1>",
+		"css/site.css": "body { color: red }",
+	})
+	// already stored by an earlier deploy
+	f.stored[gzipHash(t, "<h1>about</h1>")] = true
+
+	res, err := f.deployer().Deploy(context.Background(), Options{Config: config})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{gzipHash(t, "<h1>hello</h1>"), gzipHash(t, "body { color: red }")}
+	slices.Sort(want)
+	if got := f.uploads(); !slices.Equal(got, want) {
+		t.Errorf("uploaded %v, want only the required %v", got, want)
+	}
+
+	if len(res.Sites) != 1 {
+		t.Fatalf("got %d site results, want 1", len(res.Sites))
+	}
+	sr := res.Sites[0]
+	if sr.Error != "" {
+		t.Fatal(sr.Error)
+	}
+	if sr.Uploaded != 2 || sr.Skipped != 1 {
+		t.Errorf("uploaded %d, skipped %d, want 2 and 1", sr.Uploaded, sr.Skipped)
+	}
+	if !slices.Equal(sr.UploadedHashes, want) {
+		t.Errorf("result lists uploaded hashes %v, want %v", sr.UploadedHashes, want)
+	}
+
+	var finalized bool
+	for _, v := range f.patched {
+		if v.Name == sr.Version && v.Status == "FINALIZED" {
+			finalized = true
+		}
+	}
+	if !finalized {
+		t.Errorf("version %s wasn't finalized", sr.Version)
+	}
+	live := f.channels["sites/test/channels/live"]
+	if live == nil || live.Release == nil || live.Release.Version.Name != sr.Version {
+		t.Errorf("version %s wasn't released to live", sr.Version)
+	}
+	if got := len(f.files[sr.Version]); got != 3 {
+		t.Errorf("version has %d files, want 3", got)
+	}
+}
@@ -0,0 +1,83 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
+)
+
+// Errors returned by Deploy match one of these with errors.Is,
+// if the cause of the failure is known.
+var (
+	// ErrConfig is for invalid configs or files to deploy.
+	ErrConfig = errors.New("invalid config")
+	// ErrAuth is for missing or rejected credentials.
+	ErrAuth = errors.New("authentication failed")
+	// ErrTransient is for network failures, timeouts, and server errors,
+	// which may succeed if retried.
+	ErrTransient = errors.New("transient failure")
+	// ErrRejected is for requests the api rejected.
+	ErrRejected = errors.New("rejected by the api")
+)
+
+// classError adds a class to an error without changing its message.
+type classError struct {
+	class error
+	err   error
+}
+
+func (e *classError) Error() string   { return e.err.Error() }
+func (e *classError) Unwrap() []error { return []error{e.class, e.err} }
+
+// withClass marks err as belonging to class.
+func withClass(class, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &classError{class, err}
+}
+
+// classify marks err with a class based on its cause,
+// unless it already has one.
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+	for _, class := range []error{ErrConfig, ErrAuth, ErrTransient, ErrRejected} {
+		if errors.Is(err, class) {
+			return err
+		}
+	}
+
+	var gerr *googleapi.Error
+	var rerr *oauth2.RetrieveError
+	var retryable retryableError
+	var nerr net.Error
+	switch {
+	case errors.As(err, &gerr):
+		// quotas may be reported with 403 or 429
+		if short, ok := shortenQuota(err, gerr); ok {
+			slog.Debug("quota exceeded", "err", err)
+			return withClass(ErrTransient, short)
+		}
+		switch {
+		case gerr.Code == http.StatusUnauthorized || gerr.Code == http.StatusForbidden:
+			return withClass(ErrAuth, err)
+		case gerr.Code == http.StatusTooManyRequests || gerr.Code >= 500:
+			return withClass(ErrTransient, err)
+		}
+		return withClass(ErrRejected, err)
+	case errors.As(err, &rerr):
+		return withClass(ErrAuth, err)
+	case errors.Is(err, errURLExpired):
+		return withClass(ErrRejected, err)
+	case errors.As(err, &retryable), errors.As(err, &nerr), errors.Is(err, context.DeadlineExceeded):
+		return withClass(ErrTransient, err)
+	}
+	return err
+}
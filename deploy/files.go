@@ -0,0 +1,445 @@
+package deploy
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// maxFileSize is the largest file firebase hosting accepts.
+	maxFileSize = 2 << 30
+	// largeFileSize is the gzipped size above which a file is likely
+	// an accidentally included artifact.
+	largeFileSize = 50 << 20
+)
+
+// fileReader reads and compresses the files to deploy.
+type fileReader struct {
+	// cache holds previously computed hashes
+	cache *hashCache
+	// spool holds large gzipped contents
+	spool *spool
+	// configFS is the directory of firebase.json, checked for an ignore file
+	configFS fs.FS
+	// noDefaultIgnores only uses the configured ignore patterns
+	noDefaultIgnores bool
+	// followSymlinks descends into symlinked directories
+	followSymlinks bool
+	// level is the gzip compression level
+	level int
+	// maxFiles stops the walk early once exceeded, if positive
+	maxFiles int
+	// concurrency is the number of files compressed in parallel
+	concurrency int
+	// usePrecompressed uploads the .gz sibling of a file as its contents
+	usePrecompressed bool
+	// maxFileSize and modifiedAfter skip files by size and age, if set
+	maxFileSize   int64
+	modifiedAfter time.Time
+	// extensions, if set, are the only lowercase file extensions deployed
+	extensions map[string]bool
+
+	// ignored collects the paths of ignored files and directories,
+	// directories with a trailing /
+	ignored []string
+
+	// rawBytes and gzBytes total the sizes of the files read,
+	// before and after compression
+	rawBytes, gzBytes int64
+}
+
+// fileContent is the gzipped contents of a local file.
+// Small contents are held in memory, large ones in a temporary file.
+type fileContent struct {
+	r    *fileReader
+	fsys fs.FS
+	path string
+
+	hash string
+	// size is the gzipped size
+	size int64
+
+	mu      sync.Mutex
+	gz      []byte
+	tmpFile string
+}
+
+// load compresses the file again if its contents aren't held
+// (the hash was taken from the cache),
+// making sure they still match the hash from when the file was read.
+func (c *fileContent) load() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.gz != nil || c.tmpFile != "" {
+		return nil
+	}
+	fresh, err := c.r.compressFile(c.fsys, c.path)
+	if err != nil {
+		return err
+	}
+	if fresh.hash != c.hash {
+		return fmt.Errorf("%s changed since it was read", c.path)
+	}
+	c.gz, c.tmpFile = fresh.gz, fresh.tmpFile
+	return nil
+}
+
+// open returns a new reader over the gzipped contents,
+// so the same contents can be read by concurrent and repeated uploads.
+func (c *fileContent) open() (io.ReadCloser, error) {
+	err := c.load()
+	if err != nil {
+		return nil, err
+	}
+	if c.tmpFile != "" {
+		return os.Open(c.tmpFile)
+	}
+	return io.NopCloser(bytes.NewReader(c.gz)), nil
+}
+
+// readFiles walks fsys, the public directory, skipping ignored files and those outside sel,
+// and returns the mapping of url paths to hashes, and hashes to gzipped contents.
+// Files with an entry in the cache matching their size and modification time
+// aren't compressed again, the cache is updated with newly computed hashes.
+func (r *fileReader) readFiles(ctx context.Context, fsys fs.FS, h *Hosting, sel selection) (map[string]string, map[string]*fileContent, error) {
+	patterns := h.Ignore
+	if !r.noDefaultIgnores {
+		patterns = append(append([]string{}, defaultIgnores...), h.Ignore...)
+	}
+	ig, err := newIgnorer(patterns)
+	if err != nil {
+		return nil, nil, err
+	}
+	inc, err := newIncluder(h.Include)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, dir := range []fs.FS{r.configFS, fsys} {
+		if dir == nil {
+			continue
+		}
+		err = ig.addFile(dir)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var files []*readFile
+	var tooLarge []string
+	var skippedExt int
+	var walk fs.WalkDirFunc
+	walk = func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return fmt.Errorf("stat %s: %w", p, err)
+		}
+		symlink := d.Type()&fs.ModeSymlink != 0
+		if symlink {
+			// symlinked files are deployed with their target's content
+			fi, err = fs.Stat(fsys, p)
+			if errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("/%s: broken symlink", p)
+			} else if errors.Is(err, syscall.ELOOP) {
+				return fmt.Errorf("/%s: symlink loop", p)
+			} else if err != nil {
+				return fmt.Errorf("stat %s: %w", p, err)
+			}
+		}
+		if p != "." && ig.match(p, fi.IsDir()) || p == ignoreFileName {
+			if d.IsDir() {
+				r.ignored = append(r.ignored, "/"+p+"/")
+				return fs.SkipDir
+			} else if fi.IsDir() {
+				r.ignored = append(r.ignored, "/"+p+"/")
+				return nil
+			}
+			r.ignored = append(r.ignored, "/"+p)
+			return nil
+		}
+		if fi.IsDir() {
+			if !sel.contains(p) || !inc.contains(p) {
+				if symlink {
+					// SkipDir on a non directory entry would skip its siblings
+					return nil
+				}
+				return fs.SkipDir
+			} else if !symlink {
+				return nil
+			} else if !r.followSymlinks {
+				slog.Warn("skipping symlinked directory, use -follow-symlinks to include it", "path", "/"+p)
+				return nil
+			}
+			err = symlinkLoop(fsys, p, fi)
+			if err != nil {
+				return err
+			}
+			return fs.WalkDir(fsys, p, walk)
+		} else if !sel.includes(p) || !inc.includes(p) {
+			return nil
+		}
+
+		if r.extensions != nil && !r.extensions[strings.ToLower(path.Ext(p))] {
+			skippedExt++
+			return nil
+		} else if r.maxFileSize > 0 && fi.Size() > r.maxFileSize {
+			slog.Debug("skipping file larger than the max file size", "path", "/"+p, "bytes", fi.Size())
+			return nil
+		} else if !r.modifiedAfter.IsZero() && fi.ModTime().Before(r.modifiedAfter) {
+			slog.Debug("skipping file modified before the cutoff", "path", "/"+p, "modified", fi.ModTime())
+			return nil
+		}
+		if fi.Size() > maxFileSize {
+			// keep walking to report all of them at once
+			tooLarge = append(tooLarge, fmt.Sprintf("/%s (%d bytes)", p, fi.Size()))
+			return nil
+		}
+		stat := fi
+		if r.usePrecompressed {
+			if gzfi, ok := precompressed(fsys, p); ok {
+				// the .gz is what's read, so it's what must be unchanged for the cache
+				stat = gzfi
+			} else if orig, ok := strings.CutSuffix(p, ".gz"); ok && isRegular(fsys, orig) {
+				slog.Debug("skipping precompressed file, uploaded as its original", "path", "/"+p)
+				return nil
+			}
+		}
+		if r.maxFiles > 0 && len(files) >= r.maxFiles {
+			return tooManyFiles(r.maxFiles)
+		}
+		content, cached := r.cache.lookup(p, stat, r.level)
+		files = append(files, &readFile{p, fi, stat, content, cached})
+		return nil
+	}
+	err = fs.WalkDir(fsys, ".", walk)
+	if err != nil {
+		return nil, nil, fmt.Errorf("walk %s: %w", h.Public, err)
+	}
+	if len(tooLarge) > 0 {
+		return nil, nil, fmt.Errorf("files exceed the %d byte limit: %s", maxFileSize, strings.Join(tooLarge, ", "))
+	}
+	if skippedExt > 0 {
+		slog.Debug("skipped files with other extensions", "files", skippedExt)
+	}
+
+	err = r.compressAll(ctx, fsys, files)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the results are collected in walk order,
+	// so the same path is always chosen to name shared contents
+	pathToHash := make(map[string]string, len(files))
+	hashToContent := make(map[string]*fileContent)
+	for _, f := range files {
+		content := f.content
+		if !f.cached {
+			r.cache.store(f.path, f.stat, r.level, content)
+		}
+		content.r, content.fsys, content.path = r, fsys, f.path
+
+		if content.size > largeFileSize {
+			slog.Warn("large file, is it meant to be deployed?", "path", "/"+f.path, "gzipped_bytes", content.size)
+		}
+
+		pathToHash["/"+f.path] = content.hash
+		r.rawBytes += f.fi.Size()
+		r.gzBytes += content.size
+		slog.Debug("read file", "path", "/"+f.path, "hash", content.hash, "raw_bytes", f.fi.Size(), "bytes", content.size, "cached", f.cached)
+		// identical files share a single copy of their contents,
+		// uploads each read it through their own reader
+		if _, ok := hashToContent[content.hash]; !ok {
+			hashToContent[content.hash] = content
+		}
+	}
+	return pathToHash, hashToContent, nil
+}
+
+// readFile is a file found by the walk,
+// content is nil until it's compressed unless it was cached.
+type readFile struct {
+	path string
+	fi   fs.FileInfo
+	// stat is the info of the file actually read, checked by the cache
+	stat    fs.FileInfo
+	content *fileContent
+	cached  bool
+}
+
+// compressAll compresses the files without cached contents
+// with a pool of workers, stopping at the first error.
+func (r *fileReader) compressAll(ctx context.Context, fsys fs.FS, files []*readFile) error {
+	concurrency := r.concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	jobs := make(chan *readFile)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for f := range jobs {
+				if ctx.Err() != nil {
+					continue
+				}
+				content, err := r.compressFile(fsys, f.path)
+				if err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					continue
+				}
+				f.content = content
+			}
+		}()
+	}
+
+send:
+	for _, f := range files {
+		if f.cached {
+			continue
+		}
+		select {
+		case jobs <- f:
+		case <-ctx.Done():
+			break send
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if firstErr == nil {
+		firstErr = ctx.Err()
+	}
+	return firstErr
+}
+
+func tooManyFiles(max int) error {
+	return fmt.Errorf("more than %d files to deploy, ignore unneeded files in firebase.json or %s, or raise the limit", max, ignoreFileName)
+}
+
+// symlinkLoop returns an error if the directory symlink at p,
+// with target info fi, points to one of its parent directories,
+// which would be walked forever if followed.
+func symlinkLoop(fsys fs.FS, p string, fi fs.FileInfo) error {
+	for dir := path.Dir(p); ; dir = path.Dir(dir) {
+		dfi, err := fs.Stat(fsys, dir)
+		if err != nil {
+			return fmt.Errorf("stat %s: %w", dir, err)
+		}
+		if os.SameFile(fi, dfi) {
+			return fmt.Errorf("/%s: symlink loop, it points to its parent /%s, symlinked directories are only followed if they're outside their own path", p, strings.TrimPrefix(dir, "."))
+		}
+		if dir == "." {
+			return nil
+		}
+	}
+}
+
+// extensionSet normalizes extensions to lowercase with a leading dot,
+// returning nil if there are none.
+func extensionSet(exts []string) map[string]bool {
+	if len(exts) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(exts))
+	for _, ext := range exts {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext != "" && !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		set[ext] = true
+	}
+	return set
+}
+
+// precompressed returns the info of the gzipped sibling p.gz of p, if it exists.
+func precompressed(fsys fs.FS, p string) (fs.FileInfo, bool) {
+	fi, err := fs.Stat(fsys, p+".gz")
+	return fi, err == nil && fi.Mode().IsRegular()
+}
+
+func isRegular(fsys fs.FS, p string) bool {
+	fi, err := fs.Stat(fsys, p)
+	return err == nil && fi.Mode().IsRegular()
+}
+
+// compressFile gzips the file at p,
+// hashing the gzipped bytes as they're produced.
+// With usePrecompressed, the bytes of a p.gz sibling are used as they are.
+func (r *fileReader) compressFile(fsys fs.FS, p string) (*fileContent, error) {
+	src := p
+	var gzipped bool
+	if r.usePrecompressed {
+		if _, ok := precompressed(fsys, p); ok {
+			src, gzipped = p+".gz", true
+		}
+	}
+	f, err := fsys.Open(src)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", src, err)
+	}
+	defer f.Close()
+
+	// already compressed formats are only wrapped in gzip (as required for uploads)
+	// the hash is always over the exact bytes that will be uploaded
+	br := bufio.NewReader(f)
+	level := r.level
+	if alreadyCompressed(p, br) {
+		level = gzip.NoCompression
+	}
+	sw := &spillWriter{spool: r.spool}
+	defer sw.Close()
+	var hash string
+	if gzipped {
+		magic, _ := br.Peek(2)
+		if !bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+			return nil, fmt.Errorf("%s isn't gzipped", src)
+		}
+		hash, err = hashCopy(sw, br)
+	} else {
+		hash, err = hashGzip(sw, br, level)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("compress %s: %w", p, err)
+	}
+	err = sw.Close()
+	if err != nil {
+		return nil, fmt.Errorf("write gzipped %s: %w", p, err)
+	}
+
+	content := &fileContent{
+		hash: hash,
+		size: sw.n,
+	}
+	if sw.f != nil {
+		content.tmpFile = sw.f.Name()
+	} else {
+		content.gz = sw.buf.Bytes()
+	}
+	return content, nil
+}
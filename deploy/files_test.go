@@ -0,0 +1,81 @@
+package deploy
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"slices"
+	"testing"
+	"testing/fstest"
+)
+
+// emptyHash is the hash of a zero byte file, gzipped at the default level into 20 bytes.
+const emptyHash = "ac73670af3abed54ac6fb4695131f4099be9fbe39d6076c5d0264a6bbdae9d83"
+
+func newTestReader() *fileReader {
+	return &fileReader{
+		spool:       &spool{},
+		level:       gzip.DefaultCompression,
+		concurrency: 4,
+		configFS:    fstest.MapFS{},
+	}
+}
+
+func TestReadFilesEmpty(t *testing.T) {
+	fsys := fstest.MapFS{
+		"empty.txt":    {},
+		"dir/empty":    {},
+		"notempty.txt": {Data: []byte("hello")},
+	}
+	for i := 0; i < 2; i++ {
+		r := newTestReader()
+		defer r.spool.cleanup()
+		pathToHash, hashToContent, err := r.readFiles(context.Background(), fsys, &Hosting{}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pathToHash) != 3 {
+			t.Errorf("got %d paths, want 3: %v", len(pathToHash), pathToHash)
+		}
+		for _, p := range []string{"/empty.txt", "/dir/empty"} {
+			if got := pathToHash[p]; got != emptyHash {
+				t.Errorf("%s hash = %q, want %q", p, got, emptyHash)
+			}
+		}
+
+		c := hashToContent[emptyHash]
+		if c == nil {
+			t.Fatal("no contents for the empty hash")
+		} else if c.size != 20 {
+			t.Errorf("gzipped size = %d, want 20", c.size)
+		}
+		rc, err := c.open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		zr, err := gzip.NewReader(rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := io.ReadAll(zr)
+		rc.Close()
+		if err != nil || len(b) != 0 {
+			t.Errorf("contents decompress to %q, %v, want nothing", b, err)
+		}
+	}
+}
+
+func TestDeployEmptyFile(t *testing.T) {
+	f := newFakeAPI(t)
+	config := writeSite(t, map[string]string{"empty.txt": ""})
+	res, err := f.deployer().Deploy(context.Background(), Options{Config: config})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := f.uploads(); !slices.Equal(got, []string{emptyHash}) {
+		t.Errorf("uploaded %v, want %v", got, []string{emptyHash})
+	}
+	if got := res.Sites[0].Manifest["/empty.txt"].Hash; got != emptyHash {
+		t.Errorf("manifest hash = %q, want %q", got, emptyHash)
+	}
+}
@@ -0,0 +1,84 @@
+package deploy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+)
+
+// FirebaseRC is the subset of .firebaserc used to resolve hosting targets.
+type FirebaseRC struct {
+	Projects map[string]string `json:"projects"`
+	// Targets maps project -> resource type -> target -> sites
+	Targets map[string]map[string]map[string][]string `json:"targets"`
+}
+
+// resolveTarget sets the site for configs that use a hosting target,
+// looking it up in the .firebaserc next to fbConfFile.
+// Configs with only a site are left as is.
+func resolveTarget(fsys fs.FS, fbConfFile, project string, h *Hosting) error {
+	target := h.Target
+	if target == "" {
+		return nil
+	}
+
+	rcFile := filepath.Join(filepath.Dir(fbConfFile), ".firebaserc")
+	b, err := fs.ReadFile(fsys, ".firebaserc")
+	if errors.Is(err, fs.ErrNotExist) {
+		if abs, err := filepath.Abs(rcFile); err == nil {
+			rcFile = abs
+		}
+		return fmt.Errorf("config uses target %q but no .firebaserc found at %s; specify -site or add .firebaserc", target, rcFile)
+	} else if err != nil {
+		return fmt.Errorf("resolve target %s: read %s: %w", target, rcFile, err)
+	}
+	var rc FirebaseRC
+	err = json.Unmarshal(b, &rc)
+	if err != nil {
+		return fmt.Errorf("resolve target %s: unmarshal %s: %w", target, rcFile, err)
+	}
+
+	if p := rc.Projects[project]; p != "" {
+		// an alias
+		project = p
+	} else if project == "" {
+		project, err = rc.project()
+		if err != nil {
+			return fmt.Errorf("resolve target %s: %w", target, err)
+		}
+	}
+	sites := rc.Targets[project]["hosting"][target]
+	switch len(sites) {
+	case 0:
+		return fmt.Errorf("resolve target %s: no hosting target %s for project %s in %s", target, target, project, rcFile)
+	case 1:
+		h.Site = sites[0]
+		return nil
+	default:
+		return fmt.Errorf("resolve target %s: target maps to multiple sites %v", target, sites)
+	}
+}
+
+// project returns the default project,
+// or the only project with targets if there is no default.
+func (rc *FirebaseRC) project() (string, error) {
+	if p := rc.Projects["default"]; p != "" {
+		return p, nil
+	}
+	var projects []string
+	for p := range rc.Targets {
+		projects = append(projects, p)
+	}
+	sort.Strings(projects)
+	switch len(projects) {
+	case 0:
+		return "", fmt.Errorf("no default project or targets in .firebaserc")
+	case 1:
+		return projects[0], nil
+	default:
+		return "", fmt.Errorf("no default project in .firebaserc and multiple projects with targets: %v", projects)
+	}
+}
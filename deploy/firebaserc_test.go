@@ -0,0 +1,52 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	firebasehosting "google.golang.org/api/firebasehosting/v1beta1"
+)
+
+// noAPI fails the test on any api call,
+// those other than GetSite panic on the nil API.
+type noAPI struct {
+	API
+	t *testing.T
+}
+
+func (a noAPI) GetSite(ctx context.Context, name string) (*firebasehosting.Site, error) {
+	a.t.Fatalf("unexpected api call: GetSite %s", name)
+	return nil, nil
+}
+
+func TestMissingFirebaseRC(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "firebase.json")
+	err := os.WriteFile(config, []byte(`{"hosting": {"target": "app", "public": "public"}}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Mkdir(filepath.Join(dir, "public"), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := &Deployer{API: noAPI{t: t}, Project: "test-project"}
+	_, err = d.Deploy(context.Background(), Options{Config: config})
+	if err == nil {
+		t.Fatal("deploy without a .firebaserc succeeded")
+	}
+	rcFile := filepath.Join(dir, ".firebaserc")
+	for _, want := range []string{`target "app"`, rcFile, "-site"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q doesn't mention %s", err, want)
+		}
+	}
+	if !errors.Is(err, ErrConfig) {
+		t.Errorf("error %v isn't ErrConfig", err)
+	}
+}
@@ -0,0 +1,125 @@
+package deploy
+
+import (
+	"path"
+	"strings"
+)
+
+// matchSegment reports whether the single path segment s matches pat,
+// a path.Match pattern that may also use the extended globs firebase hosting supports:
+// @(a|b) matches one of the alternatives, ?(a|b) zero or one of them,
+// +(a|b) one or more, *(a|b) zero or more, and !(a|b) anything but one of them.
+func matchSegment(pat, s string) bool {
+	op, start, end := findExtglob(pat)
+	if start < 0 {
+		ok, _ := path.Match(pat, s)
+		return ok
+	}
+	prefix, alts, rest := pat[:start], splitAlternatives(pat[start+2:end]), pat[end+1:]
+	for i := 0; i <= len(s); i++ {
+		if ok, _ := path.Match(prefix, s[:i]); !ok {
+			continue
+		}
+		for j := i; j <= len(s); j++ {
+			if matchExtglob(op, alts, s[i:j]) && matchSegment(rest, s[j:]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// findExtglob returns the operator of the first extended glob in pat
+// and the indexes of its operator and closing parenthesis,
+// or a start of -1 if there isn't one.
+func findExtglob(pat string) (op byte, start, end int) {
+	for i := 0; i < len(pat)-1; i++ {
+		switch {
+		case pat[i] == '\\':
+			i++
+		case strings.IndexByte("@?+*!", pat[i]) >= 0 && pat[i+1] == '(':
+			if end := closingParen(pat, i+1); end >= 0 {
+				return pat[i], i, end
+			}
+		}
+	}
+	return 0, -1, -1
+}
+
+// closingParen returns the index of the parenthesis closing the one at open,
+// or -1 if it's unclosed.
+func closingParen(pat string, open int) int {
+	depth := 0
+	for i := open; i < len(pat); i++ {
+		switch pat[i] {
+		case '\\':
+			i++
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// splitAlternatives splits the | separated patterns inside an extended glob,
+// ignoring those in nested ones.
+func splitAlternatives(s string) []string {
+	var alts []string
+	depth, last := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case '|':
+			if depth == 0 {
+				alts = append(alts, s[last:i])
+				last = i + 1
+			}
+		}
+	}
+	return append(alts, s[last:])
+}
+
+// matchExtglob reports whether s matches the alternatives of an extended glob with op.
+func matchExtglob(op byte, alts []string, s string) bool {
+	switch op {
+	case '@':
+		return matchAny(alts, s)
+	case '?':
+		return s == "" || matchAny(alts, s)
+	case '+':
+		return matchRepeated(alts, s)
+	case '*':
+		return s == "" || matchRepeated(alts, s)
+	default: // !
+		return !matchAny(alts, s)
+	}
+}
+
+func matchAny(alts []string, s string) bool {
+	for _, alt := range alts {
+		if matchSegment(alt, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchRepeated reports whether s is one or more matches of the alternatives.
+func matchRepeated(alts []string, s string) bool {
+	for i := 1; i <= len(s); i++ {
+		if matchAny(alts, s[:i]) && (i == len(s) || matchRepeated(alts, s[i:])) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package deploy
+
+import (
+	"context"
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestMatchSegment(t *testing.T) {
+	tests := []struct {
+		pat, s string
+		want   bool
+	}{
+		{"*.js", "app.js", true},
+		{"*.js", "app.ts", false},
+		{"@(a|b)", "a", true},
+		{"@(a|b)", "b", true},
+		{"@(a|b)", "ab", false},
+		{"@(a|b)", "", false},
+		{"?(a|b).txt", ".txt", true},
+		{"?(a|b).txt", "a.txt", true},
+		{"?(a|b).txt", "aa.txt", false},
+		{"+(a|b)", "abba", true},
+		{"+(a|b)", "", false},
+		{"*(a|b)", "", true},
+		{"*(a|b)", "abc", false},
+		{"!(x)", "y", true},
+		{"!(x)", "x", false},
+		{"!(*.map)", "app.js", true},
+		{"!(*.map)", "app.js.map", false},
+		{"*.@(map|ts)", "app.js.map", true},
+		{"*.@(map|ts)", "app.ts", true},
+		{"*.@(map|ts)", "app.js", false},
+		{"@(a|@(b|c))d", "cd", true},
+		{"@(a|@(b|c))d", "dd", false},
+		{`\@(a)`, "@(a)", true},
+		{"@(a", "@(a", true},
+	}
+	for _, tt := range tests {
+		if got := matchSegment(tt.pat, tt.s); got != tt.want {
+			t.Errorf("matchSegment(%q, %q) = %v, want %v", tt.pat, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestIgnoreExtglob(t *testing.T) {
+	ig, err := newIgnorer([]string{"**/*.@(map|ts)", "/!(keep)/**", "!**/index.d.ts"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		p     string
+		isDir bool
+		want  bool
+	}{
+		{"keep/app.js", false, false},
+		{"keep/app.js.map", false, true},
+		{"keep/types.ts", false, true},
+		{"keep/index.d.ts", false, false},
+		{"other/app.js", false, true},
+		{"other", true, false},
+		{"keep", true, false},
+		{"app.js", false, false},
+	}
+	for _, tt := range tests {
+		if got := ig.match(tt.p, tt.isDir); got != tt.want {
+			t.Errorf("match(%q, %v) = %v, want %v", tt.p, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestDeployExtglob(t *testing.T) {
+	f := newFakeAPI(t)
+	config := writeSite(t, map[string]string{
+		"index.html":     "<h1>hello</h1>",
+		"app.js":         "alert(1)",
+		"app.js.map":     "{}",
+		"types/index.ts": "export {}",
+	})
+	err := os.WriteFile(config, []byte(`{"hosting": {
+		"site": "test",
+		"public": "public",
+		"ignore": ["**/*.@(map|ts)"],
+		"headers": [{"source": "**/*.@(js|css)", "headers": [{"key": "Cache-Control", "value": "max-age=3600"}]}],
+		"redirects": [{"source": "/@(a|b)/!(x)/?(y)", "destination": "/c"}],
+		"rewrites": [{"source": "/+(app|site)/**", "destination": "/index.html"}]
+	}}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := f.deployer().Deploy(context.Background(), Options{Config: config})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	version := f.versions[res.Sites[0].Version]
+	conf := version.Config
+	if conf == nil || len(conf.Headers) != 1 || len(conf.Redirects) != 1 || len(conf.Rewrites) != 1 {
+		t.Fatalf("version config %+v doesn't have the configured rules", conf)
+	}
+	if got, want := conf.Headers[0].Glob, "**/*.@(js|css)"; got != want {
+		t.Errorf("header glob = %q, want %q", got, want)
+	}
+	if got, want := conf.Redirects[0].Glob, "/@(a|b)/!(x)/?(y)"; got != want {
+		t.Errorf("redirect glob = %q, want %q", got, want)
+	}
+	if got, want := conf.Rewrites[0].Glob, "/+(app|site)/**"; got != want {
+		t.Errorf("rewrite glob = %q, want %q", got, want)
+	}
+
+	var paths []string
+	for p := range f.files[version.Name] {
+		paths = append(paths, p)
+	}
+	slices.Sort(paths)
+	if want := []string{"/app.js", "/index.html"}; !slices.Equal(paths, want) {
+		t.Errorf("deployed %v, want %v", paths, want)
+	}
+}
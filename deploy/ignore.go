@@ -0,0 +1,170 @@
+package deploy
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// ignoreFileName is an optional file of extra ignore patterns
+// in .gitignore syntax, read from the config and public directories.
+const ignoreFileName = ".fbhignore"
+
+// defaultIgnores are the patterns the firebase cli adds to new configs,
+// applied before the configured ones, which can re-include paths with negations.
+var defaultIgnores = []string{"firebase.json", "**/.*", "**/node_modules/**"}
+
+// ignorer matches slash separated paths relative to the public directory
+// against the globs in firebase.json's ignore list and any ignore files.
+//
+// Patterns from firebase.json follow the firebase cli:
+// they are anchored at the public directory (a leading / is optional),
+// * and ? match within a single path segment,
+// and ** matches zero or more whole segments.
+// Patterns from ignore files follow .gitignore,
+// where a pattern without a / matches at any depth.
+// In both, a leading ! re-includes paths matched by earlier patterns,
+// the last matching pattern wins.
+// Segments may use extended globs like @(a|b),
+// a leading !(a|b) needs a / before it to not be read as a negation.
+type ignorer struct {
+	patterns []ignorePattern
+}
+
+type ignorePattern struct {
+	segments []string
+	dirOnly  bool
+	negate   bool
+}
+
+func newIgnorer(patterns []string) (*ignorer, error) {
+	ig := &ignorer{}
+	for _, p := range patterns {
+		err := ig.add(p, true)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ig, nil
+}
+
+// add parses a single pattern, anchored patterns are relative to the public directory,
+// otherwise patterns without a / in the middle match at any depth.
+func (ig *ignorer) add(p string, anchored bool) error {
+	pat := p
+	var negate bool
+	if strings.HasPrefix(pat, "!") {
+		pat = pat[1:]
+		negate = true
+	}
+	var dirOnly bool
+	if strings.HasSuffix(pat, "/") {
+		pat = strings.TrimSuffix(pat, "/")
+		dirOnly = true
+	}
+	if strings.HasPrefix(pat, "/") {
+		pat = strings.TrimPrefix(pat, "/")
+	} else if !anchored && !strings.Contains(pat, "/") {
+		pat = "**/" + pat
+	}
+	if pat == "" {
+		return nil
+	}
+	segments := strings.Split(pat, "/")
+	for _, seg := range segments {
+		_, err := path.Match(seg, "")
+		if err != nil {
+			return fmt.Errorf("invalid ignore pattern %q: %w", p, err)
+		}
+	}
+	ig.patterns = append(ig.patterns, ignorePattern{segments, dirOnly, negate})
+	return nil
+}
+
+// addFile adds the patterns from the ignore file in fsys, if it exists.
+func (ig *ignorer) addFile(fsys fs.FS) error {
+	b, err := fs.ReadFile(fsys, ignoreFileName)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("read %s: %w", ignoreFileName, err)
+	}
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, `\#`) || strings.HasPrefix(line, `\!`) {
+			// escaped literal # or !
+			line = line[1:]
+		}
+		err = ig.add(line, false)
+		if err != nil {
+			return fmt.Errorf("%s: %w", ignoreFileName, err)
+		}
+	}
+	return sc.Err()
+}
+
+// match reports whether p should be skipped.
+// Directories are additionally matched against patterns with a trailing /**
+// removed, so a directory whose entire contents are ignored can be pruned
+// without descending into it,
+// unless a later negated pattern could re-include some of them.
+// As with .gitignore, paths in an ignored directory can't be re-included.
+func (ig *ignorer) match(p string, isDir bool) bool {
+	segments := strings.Split(p, "/")
+	var ignored bool
+	for i, pat := range ig.patterns {
+		if pat.dirOnly && !isDir {
+			continue
+		}
+		// a trailing ** only matches what's inside a directory, not the directory itself
+		n := len(pat.segments)
+		contents := n > 1 && pat.segments[n-1] == "**"
+		self := contents && matchSegments(pat.segments[:n-1], segments)
+		if !self && matchSegments(pat.segments, segments) {
+			ignored = !pat.negate
+		} else if self && isDir && !pat.negate && !ig.reincludes(i, segments) {
+			ignored = true
+		}
+	}
+	return ignored
+}
+
+// reincludes reports whether a negated pattern after the i-th one
+// could match a path inside the directory segments.
+func (ig *ignorer) reincludes(i int, segments []string) bool {
+	for _, pat := range ig.patterns[i+1:] {
+		if pat.negate && matchPrefix(pat.segments, segments) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchSegments(pat, segments []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pat[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if !matchSegment(pat[0], segments[0]) {
+			return false
+		}
+		pat, segments = pat[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
@@ -0,0 +1,31 @@
+package deploy
+
+import "testing"
+
+func TestIgnorePrune(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		dir      string
+		want     bool
+	}{
+		{"no negations", []string{"**/node_modules/**"}, "node_modules", true},
+		{"unrelated negation", []string{"**/node_modules/**", "!.well-known"}, "node_modules", true},
+		{"nested unrelated negation", []string{"**/node_modules/**", "!.well-known/**"}, "lib/node_modules", true},
+		{"negation inside", []string{"**/node_modules/**", "!**/node_modules/keep.js"}, "node_modules", false},
+		{"negation at any depth", []string{"**/node_modules/**", "!**/keep.js"}, "node_modules", false},
+		{"negation of a sibling", []string{"/build/**", "!/dist/**"}, "build", true},
+		{"earlier negation", []string{"!/build/keep.js", "/build/**"}, "build", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ig, err := newIgnorer(tt.patterns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ig.match(tt.dir, true); got != tt.want {
+				t.Errorf("directory %s pruned %v, want %v", tt.dir, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package deploy
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// includer restricts a deploy to the paths matching any of the include globs,
+// the inverse of ignore, for configs where public contains much more than the site.
+// The globs use the same syntax as ignore patterns from firebase.json,
+// a matching directory includes everything inside it.
+// A nil includer includes everything.
+type includer struct {
+	patterns [][]string
+}
+
+func newIncluder(patterns []string) (*includer, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	inc := &includer{}
+	for _, p := range patterns {
+		pat := strings.Trim(strings.TrimPrefix(p, "./"), "/")
+		if pat == "" || pat == "." {
+			// the entire public directory
+			return nil, nil
+		}
+		segments := strings.Split(pat, "/")
+		for _, seg := range segments {
+			_, err := path.Match(seg, "")
+			if err != nil {
+				return nil, fmt.Errorf("invalid include pattern %q: %w", p, err)
+			}
+		}
+		inc.patterns = append(inc.patterns, segments)
+	}
+	return inc, nil
+}
+
+// includes reports whether p, or one of its parent directories, matches an include glob.
+func (inc *includer) includes(p string) bool {
+	if inc == nil {
+		return true
+	}
+	segments := strings.Split(p, "/")
+	for _, pat := range inc.patterns {
+		for i := 1; i <= len(segments); i++ {
+			if matchSegments(pat, segments[:i]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// contains reports whether an included path could be under the directory p,
+// ie. whether it's worth descending into p.
+func (inc *includer) contains(p string) bool {
+	if inc == nil || p == "." || inc.includes(p) {
+		return true
+	}
+	segments := strings.Split(p, "/")
+	for _, pat := range inc.patterns {
+		if matchPrefix(pat, segments) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPrefix reports whether the directory segments could be
+// the start of a path matching pat.
+func matchPrefix(pat, segments []string) bool {
+	for ; len(segments) > 0; pat, segments = pat[1:], segments[1:] {
+		if len(pat) == 0 {
+			return false
+		} else if pat[0] == "**" {
+			return true
+		}
+		if !matchSegment(pat[0], segments[0]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,205 @@
+package deploy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	firebasehosting "google.golang.org/api/firebasehosting/v1beta1"
+)
+
+// liveFiles returns the path to hash mapping of the version
+// currently released on the site's live channel,
+// or an empty map if nothing has been released yet.
+func liveFiles(ctx context.Context, client API, site string) (map[string]string, error) {
+	version, err := liveVersion(ctx, client, site)
+	if err != nil {
+		return nil, err
+	} else if version == nil {
+		return map[string]string{}, nil
+	}
+	return versionFiles(ctx, client, version.Name)
+}
+
+// liveVersion returns the version currently released on the site's live channel,
+// or nil if nothing has been released yet.
+func liveVersion(ctx context.Context, client API, site string) (*firebasehosting.Version, error) {
+	channel, err := client.GetChannel(ctx, site+"/channels/live")
+	if err != nil {
+		return nil, fmt.Errorf("get live channel for %s: %w", site, err)
+	}
+	if channel.Release == nil || channel.Release.Version == nil {
+		return nil, nil
+	}
+	return channel.Release.Version, nil
+}
+
+// unchanged reports whether the live version of site already serves
+// the same files with the same config as h and pathToHash would.
+// The api fills in defaults and drops some explicitly set fields,
+// so configs are compared by the configHash recorded when the version was created,
+// versions without one are always considered changed.
+func unchanged(ctx context.Context, client API, site string, h *Hosting, pathToHash map[string]string) (bool, error) {
+	version, err := liveVersion(ctx, client, site)
+	if err != nil || version == nil {
+		return false, err
+	}
+	want, err := configHash(servingConfig(h))
+	if err != nil {
+		return false, err
+	}
+	if hash, ok := version.Labels[configHashLabel]; !ok || hash != want {
+		return false, nil
+	}
+	return version.Labels[contentHashLabel] == treeHash(pathToHash), nil
+}
+
+// ignoredLive returns the sorted paths of live files missing from pathToHash
+// because they match one of the ignored files or directories.
+func ignoredLive(live, pathToHash map[string]string, ignored []string) []string {
+	files := make(map[string]bool)
+	var dirs []string
+	for _, p := range ignored {
+		if strings.HasSuffix(p, "/") {
+			dirs = append(dirs, p)
+		} else {
+			files[p] = true
+		}
+	}
+	var removed []string
+	for p := range live {
+		if _, ok := pathToHash[p]; ok {
+			continue
+		}
+		match := files[p]
+		for _, dir := range dirs {
+			match = match || strings.HasPrefix(p, dir)
+		}
+		if match {
+			removed = append(removed, p)
+		}
+	}
+	sort.Strings(removed)
+	return removed
+}
+
+// treeHash combines the paths and hashes in pathToHash into a single hash.
+// It is truncated to fit in a label value, which is at most 63 characters.
+func treeHash(pathToHash map[string]string) string {
+	paths := make([]string, 0, len(pathToHash))
+	for p := range pathToHash {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	h := sha256.New()
+	for _, p := range paths {
+		fmt.Fprintf(h, "%s\x00%s\n", p, pathToHash[p])
+	}
+	return hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+// configHash hashes the json encoding of conf, truncated to fit in a label value.
+func configHash(conf *firebasehosting.ServingConfig) (string, error) {
+	b, err := json.Marshal(conf)
+	if err != nil {
+		return "", fmt.Errorf("encode serving config: %w", err)
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])[:32], nil
+}
+
+// versionFiles returns the path to hash mapping of a version.
+func versionFiles(ctx context.Context, client API, version string) (map[string]string, error) {
+	files := make(map[string]string)
+	err := client.ListFiles(ctx, version, func(vfs []*firebasehosting.VersionFile) error {
+		for _, f := range vfs {
+			files[f.Path] = f.Hash
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list files for %s: %w", version, err)
+	}
+	return files, nil
+}
+
+// dryRun reports what a deploy would upload without creating anything.
+// PopulateFiles needs a version to be created first,
+// so the comparison is made against the currently live version instead:
+// content already present there won't need to be uploaded again.
+// It returns the paths that would be uploaded.
+func dryRun(ctx context.Context, client API, site string, pathToHash map[string]string) ([]string, error) {
+	live, err := liveFiles(ctx, client, site)
+	if err != nil {
+		return nil, err
+	}
+	liveHashes := make(map[string]bool, len(live))
+	for _, hash := range live {
+		liveHashes[hash] = true
+	}
+
+	var toUpload []string
+	for p, hash := range pathToHash {
+		if !liveHashes[hash] {
+			toUpload = append(toUpload, p)
+		}
+	}
+	sort.Strings(toUpload)
+	return toUpload, nil
+}
+
+// diffFiles compares the path to hash mapping of a new version to the live one.
+func diffFiles(live, pathToHash map[string]string) *FileDiff {
+	diff := &FileDiff{
+		New:       []string{},
+		Changed:   []string{},
+		Deleted:   []string{},
+		Unchanged: []string{},
+	}
+	for p, hash := range pathToHash {
+		liveHash, ok := live[p]
+		switch {
+		case !ok:
+			diff.New = append(diff.New, p)
+		case liveHash != hash:
+			diff.Changed = append(diff.Changed, p)
+		default:
+			diff.Unchanged = append(diff.Unchanged, p)
+		}
+	}
+	for p := range live {
+		if _, ok := pathToHash[p]; !ok {
+			diff.Deleted = append(diff.Deleted, p)
+		}
+	}
+	for _, paths := range [][]string{diff.New, diff.Changed, diff.Deleted, diff.Unchanged} {
+		sort.Strings(paths)
+	}
+	return diff
+}
+
+// mergeLive fills in the files outside sel from the live version,
+// so a partial deploy keeps them unchanged.
+// Paths inside sel are taken only from pathToHash:
+// selected files that no longer exist locally are removed.
+// With an empty sel, every live file missing from pathToHash is kept.
+func mergeLive(ctx context.Context, client API, site string, pathToHash map[string]string, sel selection) error {
+	live, err := liveFiles(ctx, client, site)
+	if err != nil {
+		return err
+	}
+	for p, hash := range live {
+		if len(sel) == 0 {
+			if _, ok := pathToHash[p]; !ok {
+				pathToHash[p] = hash
+			}
+		} else if !sel.includes(strings.TrimPrefix(p, "/")) {
+			pathToHash[p] = hash
+		}
+	}
+	return nil
+}
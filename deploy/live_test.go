@@ -0,0 +1,47 @@
+package deploy
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func TestDeploySkipUnchanged(t *testing.T) {
+	f := newFakeAPI(t)
+	config := writeSite(t, map[string]string{"index.html": "<h1>hello</h1>"})
+	// both differ from what the api returns for the version
+	err := os.WriteFile(config, []byte(`{"hosting": {
+		"site": "test",
+		"public": "public",
+		"cleanUrls": false,
+		"rewrites": [{"source": "/api/**", "function": "api"}]
+	}}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := Options{Config: config, SkipUnchanged: true}
+	res, err := f.deployer().Deploy(context.Background(), o)
+	if err != nil {
+		t.Fatal(err)
+	} else if res.Sites[0].Unchanged {
+		t.Fatal("first deploy skipped as unchanged")
+	}
+
+	res, err = f.deployer().Deploy(context.Background(), o)
+	if err != nil {
+		t.Fatal(err)
+	} else if !res.Sites[0].Unchanged {
+		t.Errorf("redeploying the same files and config created version %s", res.Sites[0].Version)
+	}
+
+	err = os.WriteFile(config, []byte(`{"hosting": {"site": "test", "public": "public", "cleanUrls": true}}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err = f.deployer().Deploy(context.Background(), o)
+	if err != nil {
+		t.Fatal(err)
+	} else if res.Sites[0].Unchanged {
+		t.Error("deploy with a changed config skipped as unchanged")
+	}
+}
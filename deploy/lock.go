@@ -0,0 +1,107 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	firebasehosting "google.golang.org/api/firebasehosting/v1beta1"
+)
+
+// lockTimeout is how long an unfinished version is considered a deploy in progress,
+// older ones are assumed to be left behind by failed deploys.
+const lockTimeout = time.Hour
+
+// checkLock acts as an advisory lock on site,
+// using the versions still being created as a record of deploys in progress.
+// Versions kept after a failed deploy, to be resumed or inspected, don't count.
+// It is called after creating version,
+// so that of two concurrent deploys, the later one always sees the earlier one and gives way.
+func checkLock(ctx context.Context, client API, site, version string) error {
+	var ours time.Time
+	var others []*firebasehosting.Version
+	err := client.ListVersions(ctx, site, func(vs []*firebasehosting.Version) error {
+		for _, v := range vs {
+			if v.Name == version {
+				ours, _ = time.Parse(time.RFC3339Nano, v.CreateTime)
+			} else if v.Status == "CREATED" && v.Labels[keptLabel] == "" {
+				others = append(others, v)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("check for concurrent deploys to %s: %w", site, err)
+	}
+	if ours.IsZero() {
+		ours = time.Now()
+	}
+
+	for _, v := range others {
+		created, err := time.Parse(time.RFC3339Nano, v.CreateTime)
+		if err != nil || ours.Sub(created) > lockTimeout {
+			continue
+		}
+		if created.Before(ours) || created.Equal(ours) && v.Name < version {
+			return withClass(ErrTransient, fmt.Errorf("another deploy to %s is in progress: version %s was created %v earlier (use -no-lock if it was abandoned)", site, v.Name, ours.Sub(created).Round(time.Second)))
+		}
+	}
+	return nil
+}
+
+// Versions created by a deploy are labelled,
+// so unfinished ones can be found and resumed.
+const (
+	versionLabel      = "deployed-by"
+	versionLabelValue = "fbhuploader"
+	// contentHashLabel records the treeHash of the version's files.
+	contentHashLabel = "content-hash"
+	// configHashLabel records the configHash of the version's serving config.
+	configHashLabel = "config-hash"
+	// keptLabel marks a version left behind by a failed deploy with KeepFailed or Resume.
+	// Resuming the version removes it.
+	keptLabel = "kept"
+)
+
+// versionLabels returns the labels for a new version with the serving config conf
+// and the files in pathToHash.
+func versionLabels(conf *firebasehosting.ServingConfig, pathToHash map[string]string) (map[string]string, error) {
+	hash, err := configHash(conf)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		versionLabel:     versionLabelValue,
+		contentHashLabel: treeHash(pathToHash),
+		configHashLabel:  hash,
+	}, nil
+}
+
+// findDraft returns the newest unfinished version of site left behind by a deploy,
+// or an empty string if there isn't one.
+// Versions kept after a failed deploy can be resumed at any age,
+// others only once they're older than lockTimeout,
+// as until then they may belong to a deploy still in progress.
+func findDraft(ctx context.Context, client API, site string) (string, error) {
+	var draft string
+	var newest time.Time
+	err := client.ListVersions(ctx, site, func(vs []*firebasehosting.Version) error {
+		for _, v := range vs {
+			if v.Status != "CREATED" || v.Labels[versionLabel] != versionLabelValue {
+				continue
+			}
+			created, err := time.Parse(time.RFC3339Nano, v.CreateTime)
+			if err != nil || v.Labels[keptLabel] == "" && time.Since(created) <= lockTimeout {
+				continue
+			}
+			if created.After(newest) {
+				draft, newest = v.Name, created
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("find unfinished versions of %s: %w", site, err)
+	}
+	return draft, nil
+}
@@ -0,0 +1,99 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	firebasehosting "google.golang.org/api/firebasehosting/v1beta1"
+)
+
+func TestLockIgnoresKeptVersions(t *testing.T) {
+	for _, o := range []Options{{KeepFailed: true}, {Resume: true}} {
+		f := newFakeAPI(t)
+		config := writeSite(t, map[string]string{"index.html": "<h1>hello</h1>"})
+		o.Config = config
+		hash := gzipHash(t, "<h1>hello</h1>")
+		f.failUploads[hash] = http.StatusBadRequest
+		_, err := f.deployer().Deploy(context.Background(), o)
+		if err == nil {
+			t.Fatal("deploy with a rejected upload succeeded")
+		}
+		var kept string
+		for name, v := range f.versions {
+			if v.Status == "CREATED" && v.Labels[keptLabel] != "" {
+				kept = name
+			}
+		}
+		if kept == "" {
+			t.Fatalf("KeepFailed %v, Resume %v: failed version wasn't kept", o.KeepFailed, o.Resume)
+		}
+
+		delete(f.failUploads, hash)
+		res, err := f.deployer().Deploy(context.Background(), o)
+		if errors.Is(err, ErrTransient) {
+			t.Fatalf("KeepFailed %v, Resume %v: kept version %s locked the site: %v", o.KeepFailed, o.Resume, kept, err)
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if resumed := res.Sites[0].Version == kept; resumed != o.Resume {
+			t.Errorf("Resume %v: resumed kept version %v", o.Resume, resumed)
+		}
+	}
+}
+
+func TestLockInProgress(t *testing.T) {
+	f := newFakeAPI(t)
+	config := writeSite(t, map[string]string{"index.html": "<h1>hello</h1>"})
+	_, err := f.CreateVersion(context.Background(), "sites/test", &firebasehosting.Version{Labels: map[string]string{versionLabel: versionLabelValue}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.deployer().Deploy(context.Background(), Options{Config: config})
+	if !errors.Is(err, ErrTransient) {
+		t.Errorf("deploy alongside one in progress = %v, want ErrTransient", err)
+	}
+}
+
+func TestResumeDrafts(t *testing.T) {
+	tests := []struct {
+		name   string
+		kept   bool
+		age    time.Duration
+		resume bool
+	}{
+		{"in progress", false, time.Minute, false},
+		{"crashed", false, 2 * lockTimeout, true},
+		{"kept", true, time.Minute, true},
+		{"kept long ago", true, 24 * time.Hour, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFakeAPI(t)
+			config := writeSite(t, map[string]string{"index.html": "<h1>hello</h1>"})
+			labels := map[string]string{versionLabel: versionLabelValue}
+			if tt.kept {
+				labels[keptLabel] = "true"
+			}
+			draft, err := f.CreateVersion(context.Background(), "sites/test", &firebasehosting.Version{Labels: labels})
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.versions[draft.Name].CreateTime = time.Now().Add(-tt.age).UTC().Format(time.RFC3339Nano)
+
+			// without the lock, only findDraft keeps a deploy from adopting the draft
+			res, err := f.deployer().Deploy(context.Background(), Options{Config: config, Resume: true, NoLock: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resumed := res.Sites[0].Version == draft.Name; resumed != tt.resume {
+				t.Errorf("resumed %s %v, want %v", draft.Name, resumed, tt.resume)
+			}
+			if !tt.resume && len(f.files[draft.Name]) != 0 {
+				t.Errorf("files added to the draft of a deploy in progress")
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package deploy
+
+import (
+	"path"
+	"strings"
+)
+
+// selection restricts a deploy to a set of files and directories,
+// given as slash separated paths relative to the public directory.
+// An empty selection includes everything.
+type selection []string
+
+func newSelection(paths []string) selection {
+	var sel selection
+	for _, p := range paths {
+		p = path.Clean("/" + strings.TrimPrefix(p, "./"))
+		if p == "/" {
+			// the entire public directory
+			return nil
+		}
+		sel = append(sel, p[1:])
+	}
+	return sel
+}
+
+// includes reports whether p is one of the selected paths or inside one.
+func (sel selection) includes(p string) bool {
+	if len(sel) == 0 {
+		return true
+	}
+	for _, s := range sel {
+		if p == s || strings.HasPrefix(p, s+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// contains reports whether a selected path could be under the directory p,
+// ie. whether it's worth descending into p.
+func (sel selection) contains(p string) bool {
+	if len(sel) == 0 || p == "." {
+		return true
+	}
+	for _, s := range sel {
+		if strings.HasPrefix(s, p+"/") {
+			return true
+		}
+	}
+	return sel.includes(p)
+}
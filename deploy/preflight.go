@@ -0,0 +1,55 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"google.golang.org/api/googleapi"
+)
+
+// preflight makes a cheap read of site before anything is created,
+// so missing scopes or permissions are reported plainly
+// instead of as a 403 from creating a version.
+func preflight(ctx context.Context, client API, site string) error {
+	_, err := client.GetChannel(ctx, site+"/channels/live")
+	var gerr *googleapi.Error
+	switch {
+	case err == nil:
+		return nil
+	case !errors.As(err, &gerr):
+		return fmt.Errorf("get live channel for %s: %w", site, err)
+	case insufficientScopes(gerr):
+		return withClass(ErrAuth, fmt.Errorf("credentials are missing the scopes %s, for user credentials run: gcloud auth application-default login --scopes=%s (%w)",
+			strings.Join(scopes, ", "), strings.Join(scopes, ","), err))
+	case gerr.Code == http.StatusForbidden:
+		return withClass(ErrAuth, fmt.Errorf("credentials aren't allowed to deploy to %s, grant them the Firebase Hosting Admin role (roles/firebasehosting.admin) (%w)", site, err))
+	case gerr.Code == http.StatusNotFound:
+		return withClass(ErrConfig, fmt.Errorf("%s not found, check the site name or project (%w)", site, err))
+	}
+	return fmt.Errorf("get live channel for %s: %w", site, err)
+}
+
+// insufficientScopes reports whether gerr rejected an access token
+// that wasn't granted the required scopes.
+func insufficientScopes(gerr *googleapi.Error) bool {
+	if gerr.Code != http.StatusForbidden {
+		return false
+	}
+	if strings.Contains(gerr.Header.Get("WWW-Authenticate"), "insufficient_scope") {
+		return true
+	}
+	for _, item := range gerr.Errors {
+		if item.Reason == "insufficientPermissions" && strings.Contains(item.Message, "scope") {
+			return true
+		}
+	}
+	for _, detail := range gerr.Details {
+		if m, ok := detail.(map[string]any); ok && m["reason"] == "ACCESS_TOKEN_SCOPE_INSUFFICIENT" {
+			return true
+		}
+	}
+	return strings.Contains(strings.ToLower(gerr.Message), "insufficient authentication scopes")
+}
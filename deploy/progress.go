@@ -0,0 +1,89 @@
+package deploy
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// progressInterval is how often progress is logged when not writing to a terminal.
+const progressInterval = 5 * time.Second
+
+// progress reports the number of completed uploads.
+// On a terminal it rewrites a single line,
+// otherwise it periodically logs a new line.
+// A nil *progress reports nothing.
+type progress struct {
+	w     io.Writer
+	tty   bool
+	total int
+
+	mu      sync.Mutex
+	done    int
+	lastLog time.Time
+}
+
+// newProgress reports to w, which may be nil for no progress.
+func newProgress(w io.Writer, total int) *progress {
+	if w == nil || total == 0 {
+		return nil
+	}
+	f, ok := w.(*os.File)
+	return &progress{
+		w:       w,
+		tty:     ok && IsTerminal(f),
+		total:   total,
+		lastLog: time.Now(),
+	}
+}
+
+// inc records a completed upload.
+func (p *progress) inc() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+	if p.tty {
+		fmt.Fprintf(p.w, "\ruploaded %d/%d files (%d%%)", p.done, p.total, p.done*100/p.total)
+		if p.done == p.total {
+			fmt.Fprintln(p.w)
+		}
+	} else if p.done == p.total || time.Since(p.lastLog) >= progressInterval {
+		fmt.Fprintf(p.w, "uploaded %d/%d files (%d%%)\n", p.done, p.total, p.done*100/p.total)
+		p.lastLog = time.Now()
+	}
+}
+
+// remaining resets the total to the completed uploads plus n more,
+// such as when the set of required uploads is fetched again.
+func (p *progress) remaining(n int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.total = p.done + n
+}
+
+// stop ends the progress line early, such as after a failed upload.
+func (p *progress) stop() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.tty && p.done != p.total {
+		fmt.Fprintln(p.w)
+	}
+}
+
+// IsTerminal reports whether f is connected to a terminal,
+// e.g. to only prompt for confirmation when someone can answer.
+func IsTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
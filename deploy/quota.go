@@ -0,0 +1,99 @@
+package deploy
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+// quotaError shortens the message of an error caused by an exceeded quota,
+// the full api response is kept in the wrapped error.
+type quotaError struct {
+	msg string
+	err error
+}
+
+func (e *quotaError) Error() string { return e.msg }
+func (e *quotaError) Unwrap() error { return e.err }
+
+// quotaReasons are the error reasons used by google apis for exceeded quotas.
+var quotaReasons = map[string]bool{
+	"rateLimitExceeded":     true,
+	"userRateLimitExceeded": true,
+	"quotaExceeded":         true,
+	"dailyLimitExceeded":    true,
+	"RATE_LIMIT_EXCEEDED":   true,
+	"RESOURCE_EXHAUSTED":    true,
+}
+
+// quotaMessage describes the quota gerr exceeded and when to retry,
+// reporting false if gerr isn't a quota error.
+func quotaMessage(gerr *googleapi.Error) (string, bool) {
+	quota := gerr.Code == http.StatusTooManyRequests
+	for _, item := range gerr.Errors {
+		quota = quota || quotaReasons[item.Reason]
+	}
+	var metric, limit, delay string
+	for _, detail := range gerr.Details {
+		m, ok := detail.(map[string]any)
+		if !ok {
+			continue
+		}
+		if reason, _ := m["reason"].(string); quotaReasons[reason] {
+			quota = true
+		}
+		if meta, ok := m["metadata"].(map[string]any); ok {
+			if s, _ := meta["quota_metric"].(string); s != "" {
+				metric = s
+			}
+			if s, _ := meta["quota_limit"].(string); s != "" {
+				limit = s
+			}
+		}
+		if s, _ := m["retryDelay"].(string); s != "" {
+			delay = s
+		}
+	}
+	if !quota {
+		return "", false
+	}
+
+	msg := "quota exceeded"
+	if metric != "" {
+		msg += " for " + metric
+	}
+	if limit != "" {
+		msg += " (" + limit + ")"
+	}
+	if delay == "" {
+		if d := retryAfter(gerr.Header); d > 0 {
+			delay = d.String()
+		}
+	}
+	if delay != "" {
+		msg += ", retry after " + delay
+	} else {
+		msg += ", retry later or request a higher quota"
+	}
+	return fmt.Sprintf("%s (%d)", msg, gerr.Code), true
+}
+
+// shortenQuota replaces the message of gerr in err with a summary of the exceeded quota.
+func shortenQuota(err error, gerr *googleapi.Error) (error, bool) {
+	msg, ok := quotaMessage(gerr)
+	if !ok {
+		return err, false
+	}
+	return &quotaError{strings.Replace(err.Error(), gerr.Error(), msg, 1), err}, true
+}
+
+// uploadLimited describes a rate limited upload.
+func uploadLimited(status string, after time.Duration) error {
+	if after > 0 {
+		return fmt.Errorf("rate limited by the upload server (%s), retry after %v", status, after)
+	}
+	return fmt.Errorf("rate limited by the upload server (%s)", status)
+}
@@ -0,0 +1,86 @@
+package deploy
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Result describes the outcome of deploying each selected hosting config.
+type Result struct {
+	Sites []*SiteResult
+}
+
+// SiteResult describes the outcome of deploying a single site.
+type SiteResult struct {
+	DryRun bool `json:"dryRun,omitempty"`
+	// Unchanged is set if nothing was deployed as the live version is the same.
+	Unchanged  bool   `json:"unchanged,omitempty"`
+	Site       string `json:"site"`
+	Version    string `json:"version,omitempty"`
+	Release    string `json:"release,omitempty"`
+	SiteURL    string `json:"siteUrl,omitempty"`
+	PreviewURL string `json:"previewUrl,omitempty"`
+	// DashboardURL links to the site's dashboard in the firebase console,
+	// the console has no stable link to a single version.
+	DashboardURL string `json:"dashboardUrl,omitempty"`
+
+	// Uploaded and Skipped count files (paths),
+	// Bytes counts the gzipped bytes sent.
+	Uploaded int   `json:"uploaded"`
+	Skipped  int   `json:"skipped"`
+	Bytes    int64 `json:"bytes"`
+
+	// RawBytes and CompressedBytes total the sizes of all the local files read,
+	// before and after gzip.
+	RawBytes        int64 `json:"rawBytes"`
+	CompressedBytes int64 `json:"compressedBytes"`
+
+	// UploadedHashes lists the sorted hashes of the contents sent.
+	UploadedHashes []string `json:"uploadedHashes,omitempty"`
+
+	// Files lists the paths that would be uploaded in a dry run.
+	Files []string `json:"files,omitempty"`
+	// Diff compares the local files to the live version, if requested.
+	Diff *FileDiff `json:"diff,omitempty"`
+
+	// Error is set if the deploy failed.
+	Error string `json:"error,omitempty"`
+
+	// Manifest maps each path in the version to its contents.
+	Manifest map[string]ManifestFile `json:"-"`
+
+	Elapsed time.Duration `json:"-"`
+}
+
+// SavedPercent is how much smaller the files are after compression.
+func (r *SiteResult) SavedPercent() float64 {
+	if r.RawBytes == 0 {
+		return 0
+	}
+	return 100 * float64(r.RawBytes-r.CompressedBytes) / float64(r.RawBytes)
+}
+
+// FileDiff categorizes paths by how they differ from the live version.
+type FileDiff struct {
+	New       []string `json:"new"`
+	Changed   []string `json:"changed"`
+	Deleted   []string `json:"deleted"`
+	Unchanged []string `json:"unchanged"`
+}
+
+// ManifestFile describes a file in the version.
+type ManifestFile struct {
+	Hash string `json:"hash"`
+	// Uploaded is set if the contents were sent in this deploy,
+	// otherwise they were already on the server.
+	Uploaded bool `json:"uploaded"`
+}
+
+func (r *SiteResult) MarshalJSON() ([]byte, error) {
+	type plain SiteResult
+	return json.Marshal(struct {
+		*plain
+		SavedPercent   float64 `json:"savedPercent"`
+		ElapsedSeconds float64 `json:"elapsedSeconds"`
+	}{(*plain)(r), r.SavedPercent(), r.Elapsed.Seconds()})
+}
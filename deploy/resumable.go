@@ -0,0 +1,144 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+const (
+	// resumableSize is the gzipped size from which files are uploaded
+	// with the resumable upload protocol,
+	// so a failed attempt only resends what the server didn't receive.
+	resumableSize = 32 << 20
+	// resumableChunkSize is the size of each request in a resumable upload,
+	// rounded up to the granularity the server asks for.
+	resumableChunkSize = 8 << 20
+)
+
+// errNoResumable is returned if the upload url doesn't accept resumable uploads.
+var errNoResumable = errors.New("resumable uploads not supported")
+
+// resumableUpload is a session of the resumable upload protocol used by google's upload endpoints.
+type resumableUpload struct {
+	url   string
+	chunk int64
+	// offset is how much the server has received,
+	// unknown after a failed request until queried.
+	offset  int64
+	unknown bool
+}
+
+// uploadResumable uploads content in chunks, continuing the session in *sess if set.
+// A session is started on the first attempt, and kept in *sess for later ones.
+// If the server doesn't support resumable uploads,
+// content is uploaded in a single request, as are all later files.
+func (u *uploader) uploadResumable(ctx context.Context, uploadHash string, content *fileContent, sess **resumableUpload) error {
+	if *sess == nil {
+		s, err := u.startResumable(ctx, uploadHash, content.size)
+		if errors.Is(err, errNoResumable) {
+			slog.Info("upload url doesn't support resumable uploads, uploading in one request", "err", err)
+			u.noResumable.Store(true)
+			return u.uploadOnce(ctx, uploadHash, content)
+		} else if err != nil {
+			return err
+		}
+		*sess = s
+	}
+	s := *sess
+
+	if s.unknown {
+		done, err := s.query(ctx, u)
+		if err != nil {
+			return s.fail(sess, err)
+		} else if done {
+			return nil
+		}
+		slog.Debug("resuming upload", "hash", uploadHash, "offset", s.offset, "bytes", content.size)
+	}
+
+	body, err := content.open()
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	_, err = io.CopyN(io.Discard, body, s.offset)
+	if err != nil {
+		return fmt.Errorf("skip to offset %d: %w", s.offset, err)
+	}
+	for s.offset < content.size {
+		n := min(s.chunk, content.size-s.offset)
+		cmd := "upload"
+		if s.offset+n == content.size {
+			cmd = "upload, finalize"
+		}
+		_, err := u.post(ctx, s.url, http.Header{
+			"X-Goog-Upload-Command": {cmd},
+			"X-Goog-Upload-Offset":  {strconv.FormatInt(s.offset, 10)},
+		}, io.LimitReader(body, n), n)
+		if err != nil {
+			return s.fail(sess, err)
+		}
+		s.offset += n
+	}
+	return nil
+}
+
+// fail records a failed request in the session,
+// abandoning it if the server rejected it so the next attempt starts afresh.
+func (s *resumableUpload) fail(sess **resumableUpload, err error) error {
+	s.unknown = true
+	if errors.Is(err, ErrRejected) {
+		*sess = nil
+		return retryableError{err: fmt.Errorf("resumable upload session rejected: %w", err)}
+	}
+	return err
+}
+
+// startResumable starts a resumable upload session for size bytes.
+func (u *uploader) startResumable(ctx context.Context, uploadHash string, size int64) (*resumableUpload, error) {
+	h, err := u.post(ctx, u.uploadURL+"/"+uploadHash, http.Header{
+		"X-Goog-Upload-Protocol":              {"resumable"},
+		"X-Goog-Upload-Command":               {"start"},
+		"X-Goog-Upload-Header-Content-Length": {strconv.FormatInt(size, 10)},
+		"X-Goog-Upload-Header-Content-Type":   {"application/octet-stream"},
+	}, nil, 0)
+	if errors.Is(err, ErrRejected) {
+		return nil, fmt.Errorf("%w: %w", errNoResumable, err)
+	} else if err != nil {
+		return nil, err
+	}
+	url := h.Get("X-Goog-Upload-URL")
+	if url == "" {
+		return nil, fmt.Errorf("%w: no session url in response", errNoResumable)
+	}
+	chunk := int64(resumableChunkSize)
+	if g, err := strconv.ParseInt(h.Get("X-Goog-Upload-Chunk-Granularity"), 10, 64); err == nil && g > 0 {
+		chunk = (chunk + g - 1) / g * g
+	}
+	return &resumableUpload{url: url, chunk: chunk}, nil
+}
+
+// query asks the server how much of the upload it has received,
+// reporting whether the upload is already complete.
+func (s *resumableUpload) query(ctx context.Context, u *uploader) (bool, error) {
+	h, err := u.post(ctx, s.url, http.Header{
+		"X-Goog-Upload-Command": {"query"},
+	}, nil, 0)
+	if err != nil {
+		return false, err
+	}
+	if h.Get("X-Goog-Upload-Status") == "final" {
+		return true, nil
+	}
+	offset, err := strconv.ParseInt(h.Get("X-Goog-Upload-Size-Received"), 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("query resumable upload: invalid received size: %w", err)
+	}
+	s.offset, s.unknown = offset, false
+	return false, nil
+}
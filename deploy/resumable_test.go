@@ -0,0 +1,105 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// resumableServer implements enough of the resumable upload protocol for a single upload,
+// failing the first data request after receiving half of it.
+type resumableServer struct {
+	mu       sync.Mutex
+	received []byte
+	final    bool
+	failed   bool
+	// offsets records the offset of each data request
+	offsets []int64
+}
+
+func (s *resumableServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := func() {
+		w.Header().Set("X-Goog-Upload-Size-Received", strconv.Itoa(len(s.received)))
+		if s.final {
+			w.Header().Set("X-Goog-Upload-Status", "final")
+		} else {
+			w.Header().Set("X-Goog-Upload-Status", "active")
+		}
+	}
+	switch cmd := r.Header.Get("X-Goog-Upload-Command"); cmd {
+	case "start":
+		w.Header().Set("X-Goog-Upload-URL", "http://"+r.Host+"/session")
+		status()
+	case "query":
+		status()
+	case "upload", "upload, finalize":
+		offset, _ := strconv.ParseInt(r.Header.Get("X-Goog-Upload-Offset"), 10, 64)
+		s.offsets = append(s.offsets, offset)
+		if offset != int64(len(s.received)) {
+			http.Error(w, "offset doesn't match the received size", http.StatusBadRequest)
+			return
+		}
+		if !s.failed {
+			s.failed = true
+			half := make([]byte, r.ContentLength/2)
+			n, _ := io.ReadFull(r.Body, half)
+			s.received = append(s.received, half[:n]...)
+			http.Error(w, "connection lost", http.StatusServiceUnavailable)
+			return
+		}
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.received = append(s.received, b...)
+		s.final = cmd == "upload, finalize"
+		status()
+	default:
+		http.Error(w, "unknown command "+cmd, http.StatusBadRequest)
+	}
+}
+
+func TestUploadResumableResumes(t *testing.T) {
+	s := &resumableServer{}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+
+	data := make([]byte, 64<<10)
+	rand.Read(data)
+	content := &fileContent{path: "large.bin", hash: "hash", size: int64(len(data)), gz: data}
+	u := &uploader{httpClient: srv.Client(), uploadURL: srv.URL + "/files"}
+
+	var sess *resumableUpload
+	err := u.uploadResumable(context.Background(), "hash", content, &sess)
+	var rerr retryableError
+	if !errors.As(err, &rerr) {
+		t.Fatalf("first attempt = %v, want a retryable error", err)
+	} else if sess == nil {
+		t.Fatal("session abandoned after a transient failure")
+	}
+
+	err = u.uploadResumable(context.Background(), "hash", content, &sess)
+	if err != nil {
+		t.Fatalf("resumed attempt: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.final || !bytes.Equal(s.received, data) {
+		t.Errorf("server received %d of %d bytes, final %v", len(s.received), len(data), s.final)
+	}
+	half := int64(len(data) / 2)
+	if len(s.offsets) != 2 || s.offsets[0] != 0 || s.offsets[1] != half {
+		t.Errorf("data requests at offsets %v, want [0 %d]", s.offsets, half)
+	}
+}
@@ -0,0 +1,127 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	firebasehosting "google.golang.org/api/firebasehosting/v1beta1"
+)
+
+// Rollback releases an existing version of site to its live channel again,
+// without uploading anything.
+// The version is either a full sites/SITE/versions/VERSION name or just the VERSION id.
+// If empty, the version released before the current one is used.
+// Only finalized versions can be released.
+func (d *Deployer) Rollback(ctx context.Context, o Options, site, version string) (*firebasehosting.Release, error) {
+	o = o.withDefaults()
+	if o.ReleaseType == "" {
+		o.ReleaseType = "ROLLBACK"
+	}
+	err := checkReleaseType(o.ReleaseType)
+	if err != nil {
+		return nil, withClass(ErrConfig, err)
+	}
+	_, client, _, err := d.clients(ctx, o)
+	if err != nil {
+		return nil, err
+	}
+	parent := "sites/" + site
+	if version == "" {
+		version, err = previousVersion(ctx, client, parent)
+		if err != nil {
+			return nil, classify(err)
+		}
+	} else {
+		version, err = versionName(parent, version)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = checkFinalized(ctx, client, parent, version)
+	if err != nil {
+		return nil, classify(err)
+	}
+
+	if o.Confirm != nil {
+		ok, err := o.Confirm(fmt.Sprintf("release %s to the live channel of %s?", version, parent))
+		if err != nil {
+			return nil, err
+		} else if !ok {
+			return nil, errors.New("rollback cancelled")
+		}
+	}
+	message := o.Message
+	if message == "" {
+		message = "rollback to " + version
+	}
+	rel, err := release(ctx, client, parent, version, message, o.ReleaseType)
+	if err != nil {
+		return nil, classify(err)
+	}
+	return rel, nil
+}
+
+// versionName returns the full name of version, a name or id of a version of site.
+func versionName(site, version string) (string, error) {
+	if !strings.Contains(version, "/") {
+		return site + "/versions/" + version, nil
+	} else if !strings.HasPrefix(version, site+"/versions/") {
+		return "", withClass(ErrConfig, fmt.Errorf("version %s doesn't belong to %s", version, site))
+	}
+	return version, nil
+}
+
+// previousVersion finds the most recently released version
+// other than the one currently released.
+func previousVersion(ctx context.Context, client API, site string) (string, error) {
+	var current, previous string
+	errDone := errors.New("done")
+	err := client.ListReleases(ctx, site, func(rs []*firebasehosting.Release) error {
+		for _, r := range rs {
+			if r.Version == nil || r.Type == "SITE_DISABLE" {
+				continue
+			}
+			if current == "" {
+				current = r.Version.Name
+			} else if r.Version.Name != current {
+				previous = r.Version.Name
+				return errDone
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDone) {
+		return "", fmt.Errorf("list releases of %s: %w", site, err)
+	}
+	if previous == "" {
+		return "", withClass(ErrConfig, fmt.Errorf("%s has no previous release to roll back to", site))
+	}
+	return previous, nil
+}
+
+// checkFinalized returns an error unless version exists and is finalized.
+func checkFinalized(ctx context.Context, client API, site, version string) error {
+	var status string
+	err := client.ListVersions(ctx, site, func(vs []*firebasehosting.Version) error {
+		for _, v := range vs {
+			if v.Name == version {
+				status = v.Status
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("list versions of %s: %w", site, err)
+	}
+	switch status {
+	case "FINALIZED":
+		return nil
+	case "":
+		return withClass(ErrConfig, fmt.Errorf("version %s not found, it may have been deleted", version))
+	default:
+		return withClass(ErrConfig, fmt.Errorf("can't release version %s with status %s, only finalized versions can be released", version, status))
+	}
+}
@@ -0,0 +1,203 @@
+package deploy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// HeaderRule sets response headers for the paths matching a glob source or a regex.
+type HeaderRule struct {
+	Source  string        `json:"source,omitempty"`
+	Regex   string        `json:"regex,omitempty"`
+	Headers []HeaderValue `json:"headers,omitempty"`
+}
+
+// HeaderValue is a single header set by a HeaderRule.
+type HeaderValue struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// RedirectRule redirects the paths matching a glob source or a regex.
+type RedirectRule struct {
+	Source      string `json:"source,omitempty"`
+	Regex       string `json:"regex,omitempty"`
+	Destination string `json:"destination,omitempty"`
+	Type        int    `json:"type,omitempty"`
+}
+
+// RewriteRule serves the paths matching a glob source or a regex
+// from another path, a cloud function, or a cloud run service.
+type RewriteRule struct {
+	Source       string           `json:"source,omitempty"`
+	Regex        string           `json:"regex,omitempty"`
+	Destination  string           `json:"destination,omitempty"`
+	Function     *FunctionRewrite `json:"function,omitempty"`
+	Run          *RunRewrite      `json:"run,omitempty"`
+	DynamicLinks bool             `json:"dynamicLinks,omitempty"`
+}
+
+// FunctionRewrite names a cloud function,
+// in firebase.json either as just its id or as an object with its region.
+type FunctionRewrite struct {
+	FunctionID string `json:"functionId,omitempty"`
+	Region     string `json:"region,omitempty"`
+}
+
+func (f *FunctionRewrite) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '"' {
+		return json.Unmarshal(b, &f.FunctionID)
+	}
+	type plain FunctionRewrite
+	return json.Unmarshal(b, (*plain)(f))
+}
+
+// RunRewrite names a cloud run service.
+type RunRewrite struct {
+	ServiceID string `json:"serviceId,omitempty"`
+	// Region defaults to us-central1, as in the firebase cli.
+	Region string `json:"region,omitempty"`
+}
+
+// validate checks the rewrite has exactly one target.
+func (r RewriteRule) validate(field string) []error {
+	var targets int
+	for _, set := range []bool{r.Destination != "", r.Function != nil, r.Run != nil, r.DynamicLinks} {
+		if set {
+			targets++
+		}
+	}
+	var errs []error
+	if targets != 1 {
+		errs = append(errs, fmt.Errorf("%s: exactly one of destination, function, run, or dynamicLinks must be set", field))
+	}
+	if r.Function != nil && r.Function.FunctionID == "" {
+		errs = append(errs, fmt.Errorf("%s: function.functionId is not set", field))
+	}
+	if r.Run != nil && r.Run.ServiceID == "" {
+		errs = append(errs, fmt.Errorf("%s: run.serviceId is not set", field))
+	}
+	return errs
+}
+
+// readRules reads a json array of rules from file,
+// in the same format as in firebase.json.
+func readRules[T any](file string) ([]T, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", file, err)
+	}
+	b, err = expandEnv(b, os.LookupEnv)
+	if err != nil {
+		return nil, fmt.Errorf("expand %s: %w", file, err)
+	}
+	var rules []T
+	err = json.Unmarshal(b, &rules)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal %s: %w", file, err)
+	}
+	return rules, nil
+}
+
+// mergeHeaders adds the rules in extra to h.
+// A rule for the same source or regex as an existing one is merged into it,
+// replacing the values of headers with the same key.
+func (h *Hosting) mergeHeaders(extra []HeaderRule) {
+	for _, rule := range extra {
+		i := -1
+		for j, existing := range h.Headers {
+			if existing.Source == rule.Source && existing.Regex == rule.Regex {
+				i = j
+			}
+		}
+		if i < 0 {
+			h.Headers = append(h.Headers, rule)
+			continue
+		}
+		merged := h.Headers[i]
+	headers:
+		for _, hdr := range rule.Headers {
+			for k, existing := range merged.Headers {
+				if http.CanonicalHeaderKey(existing.Key) == http.CanonicalHeaderKey(hdr.Key) {
+					merged.Headers[k] = hdr
+					continue headers
+				}
+			}
+			merged.Headers = append(merged.Headers, hdr)
+		}
+		h.Headers[i] = merged
+	}
+}
+
+// cacheRules parses GLOB=VALUE pairs into rules setting the Cache-Control header.
+func cacheRules(policies []string) ([]HeaderRule, error) {
+	var rules []HeaderRule
+	for _, policy := range policies {
+		glob, value, ok := strings.Cut(policy, "=")
+		if !ok || glob == "" || value == "" {
+			return nil, fmt.Errorf("invalid cache policy %q, expected GLOB=VALUE", policy)
+		}
+		rules = append(rules, HeaderRule{
+			Source:  glob,
+			Headers: []HeaderValue{{Key: "Cache-Control", Value: value}},
+		})
+	}
+	return rules, nil
+}
+
+// mergeRedirects adds the rules in extra to h.
+// A rule for the same source or regex as an existing one replaces it.
+func (h *Hosting) mergeRedirects(extra []RedirectRule) {
+rules:
+	for _, rule := range extra {
+		for i, existing := range h.Redirects {
+			if existing.Source == rule.Source && existing.Regex == rule.Regex {
+				h.Redirects[i] = rule
+				continue rules
+			}
+		}
+		h.Redirects = append(h.Redirects, rule)
+	}
+}
+
+// duplicateRules describes the sources and regexes used by more than one
+// header, redirect, or rewrite rule, where firebase hosting may pick either.
+func (h *Hosting) duplicateRules(field string) []string {
+	var dups []string
+	check := func(kind string, patterns []string) {
+		seen := make(map[string]int)
+		for i, p := range patterns {
+			if j, ok := seen[p]; ok {
+				dups = append(dups, fmt.Sprintf("%s.%s[%d] and [%d] both match %s", field, kind, j, i, p))
+				continue
+			}
+			seen[p] = i
+		}
+	}
+	var patterns []string
+	for _, rule := range h.Headers {
+		patterns = append(patterns, rulePattern(rule.Source, rule.Regex))
+	}
+	check("headers", patterns)
+	patterns = nil
+	for _, rule := range h.Redirects {
+		patterns = append(patterns, rulePattern(rule.Source, rule.Regex))
+	}
+	check("redirects", patterns)
+	patterns = nil
+	for _, rule := range h.Rewrites {
+		patterns = append(patterns, rulePattern(rule.Source, rule.Regex))
+	}
+	check("rewrites", patterns)
+	return dups
+}
+
+func rulePattern(source, regex string) string {
+	if regex != "" {
+		return "regex " + regex
+	}
+	return source
+}
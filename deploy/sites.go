@@ -0,0 +1,87 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	firebasehosting "google.golang.org/api/firebasehosting/v1beta1"
+)
+
+// Sites returns the sites of the hosting configs selected by o,
+// as site ids, not sites/SITE names.
+// Unlike Deploy, the public directories don't need to exist.
+func Sites(o Options) ([]string, error) {
+	hostings, err := o.withDefaults().hostings()
+	if err != nil {
+		return nil, err
+	}
+	var sites []string
+	for i, h := range hostings {
+		if h.Site == "" {
+			return nil, withClass(ErrConfig, fmt.Errorf("hosting[%d]: one of site or target must be set", i))
+		}
+		sites = append(sites, h.Site)
+	}
+	return sites, nil
+}
+
+// ResolveConfig returns the hosting configs selected by o as they would be deployed,
+// after expanding environment variables, applying overrides and extra rules,
+// resolving targets, and adding the default ignores.
+// Like Sites, it makes no api calls and the public directories don't need to exist.
+func ResolveConfig(o Options) (*FirebaseJSON, error) {
+	hostings, err := o.withDefaults().hostings()
+	if err != nil {
+		return nil, err
+	}
+	if !o.NoDefaultIgnores {
+		for _, h := range hostings {
+			h.Ignore = append(append([]string{}, defaultIgnores...), h.Ignore...)
+		}
+	}
+	return &FirebaseJSON{Hosting: hostings}, nil
+}
+
+// Versions lists the versions of site, newest first.
+func (d *Deployer) Versions(ctx context.Context, o Options, site string) ([]*firebasehosting.Version, error) {
+	_, client, _, err := d.clients(ctx, o.withDefaults())
+	if err != nil {
+		return nil, err
+	}
+	var versions []*firebasehosting.Version
+	err = client.ListVersions(ctx, "sites/"+site, func(vs []*firebasehosting.Version) error {
+		versions = append(versions, vs...)
+		return nil
+	})
+	if err != nil {
+		return nil, classify(fmt.Errorf("list versions of sites/%s: %w", site, err))
+	}
+	created := make(map[*firebasehosting.Version]time.Time, len(versions))
+	for _, v := range versions {
+		// unparseable times sort last
+		created[v], _ = time.Parse(time.RFC3339Nano, v.CreateTime)
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		return created[versions[i]].After(created[versions[j]])
+	})
+	return versions, nil
+}
+
+// Releases lists the releases of site, newest first.
+func (d *Deployer) Releases(ctx context.Context, o Options, site string) ([]*firebasehosting.Release, error) {
+	_, client, _, err := d.clients(ctx, o.withDefaults())
+	if err != nil {
+		return nil, err
+	}
+	var releases []*firebasehosting.Release
+	err = client.ListReleases(ctx, "sites/"+site, func(rs []*firebasehosting.Release) error {
+		releases = append(releases, rs...)
+		return nil
+	})
+	if err != nil {
+		return nil, classify(fmt.Errorf("list releases of sites/%s: %w", site, err))
+	}
+	return releases, nil
+}
@@ -0,0 +1,89 @@
+package deploy
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// spillSize is the gzipped size above which contents are written
+// to a temporary file instead of being held in memory.
+const spillSize = 8 << 20
+
+// spool holds the temporary files for large gzipped contents.
+// The directory is only created once it's needed.
+// A nil *spool keeps everything in memory.
+type spool struct {
+	mu  sync.Mutex
+	dir string
+}
+
+func (s *spool) create() (*os.File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dir == "" {
+		dir, err := os.MkdirTemp("", "fbhuploader-")
+		if err != nil {
+			return nil, fmt.Errorf("create temp dir: %w", err)
+		}
+		s.dir = dir
+	}
+	f, err := os.CreateTemp(s.dir, "*.gz")
+	if err != nil {
+		return nil, fmt.Errorf("create temp file: %w", err)
+	}
+	return f, nil
+}
+
+// cleanup removes all the temporary files.
+func (s *spool) cleanup() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dir != "" {
+		os.RemoveAll(s.dir)
+		s.dir = ""
+	}
+}
+
+// spillWriter buffers writes in memory until they exceed spillSize,
+// after which everything is moved to a temporary file from the spool.
+type spillWriter struct {
+	spool *spool
+	buf   bytes.Buffer
+	f     *os.File
+	n     int64
+}
+
+func (w *spillWriter) Write(b []byte) (int, error) {
+	if w.f == nil && (w.spool == nil || w.buf.Len()+len(b) <= spillSize) {
+		w.n += int64(len(b))
+		return w.buf.Write(b)
+	}
+	if w.f == nil {
+		f, err := w.spool.create()
+		if err != nil {
+			return 0, err
+		}
+		w.f = f
+		_, err = w.f.Write(w.buf.Bytes())
+		if err != nil {
+			return 0, fmt.Errorf("write %s: %w", w.f.Name(), err)
+		}
+		w.buf = bytes.Buffer{}
+	}
+	n, err := w.f.Write(b)
+	w.n += int64(n)
+	return n, err
+}
+
+// Close closes the temporary file if one was used.
+func (w *spillWriter) Close() error {
+	if w.f == nil {
+		return nil
+	}
+	return w.f.Close()
+}
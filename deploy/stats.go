@@ -0,0 +1,60 @@
+package deploy
+
+import (
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// uploadStats records the duration of each upload to summarize throughput.
+type uploadStats struct {
+	mu        sync.Mutex
+	start     time.Time
+	bytes     int64
+	durations []time.Duration
+}
+
+func newUploadStats() *uploadStats {
+	return &uploadStats{start: time.Now()}
+}
+
+// add records an upload of n bytes that took d, including retries.
+func (s *uploadStats) add(n int64, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bytes += n
+	s.durations = append(s.durations, d)
+}
+
+// count returns the number of uploads recorded.
+func (s *uploadStats) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.durations)
+}
+
+// log summarizes the uploads: overall throughput and the spread of upload latencies.
+func (s *uploadStats) log(version string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.durations) == 0 {
+		return
+	}
+	elapsed := time.Since(s.start)
+	ds := append([]time.Duration(nil), s.durations...)
+	sort.Slice(ds, func(i, j int) bool { return ds[i] < ds[j] })
+	percentile := func(p int) time.Duration {
+		return ds[(len(ds)-1)*p/100]
+	}
+	slog.Info("upload summary", "version", version,
+		"files", len(ds),
+		"bytes", s.bytes,
+		"elapsed", elapsed.Round(time.Millisecond),
+		"mb_per_second", float64(s.bytes)/1e6/elapsed.Seconds(),
+		"min", ds[0].Round(time.Millisecond),
+		"p50", percentile(50).Round(time.Millisecond),
+		"p95", percentile(95).Round(time.Millisecond),
+		"max", ds[len(ds)-1].Round(time.Millisecond),
+	)
+}
@@ -0,0 +1,123 @@
+package deploy
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// throttle limits the number of uploads in flight,
+// halving the limit each time the server rate limits an upload
+// and growing it back by one after as many successes as the current limit.
+// A nil *throttle doesn't limit anything.
+type throttle struct {
+	max int
+
+	mu     sync.Mutex
+	limit  int
+	active int
+	// until is when uploads may start again after a Retry-After
+	until time.Time
+	// ok counts successes since the limit last changed
+	ok int
+	// changed is closed and replaced when any of the above changes
+	changed chan struct{}
+}
+
+func newThrottle(concurrency int) *throttle {
+	return &throttle{
+		max:     concurrency,
+		limit:   concurrency,
+		changed: make(chan struct{}),
+	}
+}
+
+// acquire waits for a slot to start an upload.
+func (t *throttle) acquire(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	for {
+		t.mu.Lock()
+		wait := time.Until(t.until)
+		if t.active < t.limit && wait <= 0 {
+			t.active++
+			t.mu.Unlock()
+			return nil
+		}
+		changed := t.changed
+		t.mu.Unlock()
+
+		err := t.wait(ctx, changed, wait)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// wait blocks until changed is closed or d passes (if positive).
+func (t *throttle) wait(ctx context.Context, changed chan struct{}, d time.Duration) error {
+	var timer <-chan time.Time
+	if d > 0 {
+		tm := time.NewTimer(d)
+		defer tm.Stop()
+		timer = tm.C
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-changed:
+	case <-timer:
+	}
+	return nil
+}
+
+// release frees the slot of a finished upload,
+// recording whether it was rate limited, and for how long to pause if so.
+func (t *throttle) release(limited bool, retryAfter time.Duration) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.active--
+	switch {
+	case limited:
+		t.ok = 0
+		if t.limit > 1 {
+			t.limit /= 2
+		}
+		if until := time.Now().Add(retryAfter); until.After(t.until) {
+			t.until = until
+		}
+		slog.Warn("uploads rate limited, slowing down", "concurrency", t.limit, "retry_after", retryAfter)
+	case t.limit < t.max:
+		t.ok++
+		if t.ok >= t.limit {
+			t.ok = 0
+			t.limit++
+			slog.Info("uploads recovering from rate limit", "concurrency", t.limit)
+		}
+	}
+	close(t.changed)
+	t.changed = make(chan struct{})
+}
+
+// retryAfter parses a Retry-After header in either seconds or as a date,
+// returning 0 if it's missing or invalid.
+func retryAfter(h http.Header) time.Duration {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
@@ -0,0 +1,361 @@
+package deploy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	firebasehosting "google.golang.org/api/firebasehosting/v1beta1"
+)
+
+const (
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 30 * time.Second
+)
+
+// maxErrorBody limits how much of a failed upload's response is included in errors.
+const maxErrorBody = 1 << 10
+
+// maxURLRefreshes limits how many times an expired upload url is replaced
+// during a single deploy.
+const maxURLRefreshes = 5
+
+// errURLExpired is returned for uploads rejected by the upload url.
+var errURLExpired = errors.New("upload url rejected, it may have expired")
+
+// uploader uploads gzipped file contents to a version's upload url.
+type uploader struct {
+	httpClient *http.Client
+	uploadURL  string
+	// refresh gets a new upload url and the hashes still required by the version,
+	// used when the current url expires.
+	refresh func(ctx context.Context) (toUpload []string, uploadURL string, err error)
+
+	// concurrency is the number of parallel uploads
+	concurrency int
+	// attempts is the maximum number of tries for each file
+	attempts int
+	// timeout limits each attempt, if positive
+	timeout time.Duration
+	// progress receives upload progress, nil disables it
+	progress io.Writer
+	// verify rechecks the sha256 of each file's contents before uploading
+	verify bool
+	// throttle adapts the uploads in flight to rate limits
+	throttle *throttle
+	// noResumable is set once the upload url rejects a resumable upload
+	noResumable atomic.Bool
+	// stats records upload durations
+	stats *uploadStats
+	// loggedProto is set once the negotiated protocol is logged
+	loggedProto atomic.Bool
+}
+
+// uploadFiles uploads the gzipped contents for each hash in toUpload,
+// then finalizes the version.
+// The first failed upload cancels the rest,
+// unless it was caused by an expired upload url,
+// in which case a new one is requested and the remaining uploads resume.
+func uploadFiles(ctx context.Context, client API, u *uploader, version string, toUpload []string, hashToContent map[string]*fileContent) error {
+	prog := newProgress(u.progress, len(toUpload))
+	if u.throttle == nil {
+		u.throttle = newThrottle(u.concurrency)
+	}
+	if u.stats == nil {
+		u.stats = newUploadStats()
+	}
+	// uploaded contents are stored by hash,
+	// so a later deploy only needs to upload the rest
+	total := len(toUpload)
+	for refreshes := 0; ; refreshes++ {
+		err := u.uploadAll(ctx, toUpload, hashToContent, prog)
+		if err == nil {
+			break
+		} else if ctx.Err() != nil {
+			prog.stop()
+			return fmt.Errorf("deploy cancelled after %d of %d uploads: %w", u.stats.count(), total, context.Cause(ctx))
+		} else if !errors.Is(err, errURLExpired) || u.refresh == nil || refreshes == maxURLRefreshes {
+			prog.stop()
+			return fmt.Errorf("%d of %d uploads completed: %w", u.stats.count(), total, err)
+		}
+
+		slog.Info("refreshing upload url", "version", version, "err", err)
+		toUpload, u.uploadURL, err = u.refresh(ctx)
+		if err != nil {
+			prog.stop()
+			return err
+		}
+		prog.remaining(len(toUpload))
+	}
+
+	u.stats.log(version)
+	slog.Info("finalizing version", "version", version)
+	patchResponse, err := client.PatchVersion(ctx, &firebasehosting.Version{
+		Name:   version,
+		Status: "FINALIZED",
+	}, "")
+	if err != nil {
+		return fmt.Errorf("finalize %s: %w", version, err)
+	}
+	if patchResponse.Status != "FINALIZED" {
+		return fmt.Errorf("unexpected finalization status: %v", patchResponse.Status)
+	}
+	return nil
+}
+
+// uploadAll uploads toUpload with a pool of workers,
+// stopping at the first error.
+func (u *uploader) uploadAll(ctx context.Context, toUpload []string, hashToContent map[string]*fileContent, prog *progress) error {
+	concurrency := u.concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	hashes := make(chan string)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for uploadHash := range hashes {
+				if ctx.Err() != nil {
+					// drain the remaining hashes without starting new uploads
+					continue
+				}
+				err := u.uploadFile(ctx, uploadHash, hashToContent[uploadHash])
+				if err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					continue
+				}
+				prog.inc()
+			}
+		}()
+	}
+
+send:
+	for _, uploadHash := range toUpload {
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case hashes <- uploadHash:
+		case <-ctx.Done():
+			break send
+		}
+	}
+	close(hashes)
+	wg.Wait()
+	if firstErr == nil {
+		firstErr = ctx.Err()
+	}
+	return firstErr
+}
+
+// uploadFile uploads a single gzipped file,
+// naming one of the paths with its contents in errors.
+func (u *uploader) uploadFile(ctx context.Context, uploadHash string, content *fileContent) error {
+	if content == nil {
+		return fmt.Errorf("upload required for %s, which doesn't match any local file", uploadHash)
+	}
+	start := time.Now()
+	err := u.uploadContent(ctx, uploadHash, content)
+	if err != nil {
+		return fmt.Errorf("upload failed for /%s (hash %s): %w", content.path, uploadHash, err)
+	}
+	d := time.Since(start)
+	if u.stats != nil {
+		u.stats.add(content.size, d)
+	}
+	slog.Debug("uploaded", "path", "/"+content.path, "hash", uploadHash, "bytes", content.size, "duration", d.Round(time.Millisecond))
+	return nil
+}
+
+// uploadContent uploads the contents for uploadHash,
+// retrying network errors and 429/5xx responses with exponential backoff,
+// or after the delay requested by a Retry-After header.
+func (u *uploader) uploadContent(ctx context.Context, uploadHash string, content *fileContent) error {
+	if u.verify {
+		err := verifyContent(uploadHash, content)
+		if err != nil {
+			return err
+		}
+	}
+
+	attempts := u.attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	var err error
+	var delay time.Duration
+	var sess *resumableUpload
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			serr := sleep(ctx, delay)
+			if serr != nil {
+				return serr
+			}
+		}
+		err = u.throttle.acquire(ctx)
+		if err != nil {
+			return err
+		}
+		slog.Debug("uploading", "path", "/"+content.path, "hash", uploadHash, "bytes", content.size, "attempt", attempt+1)
+		if content.size >= resumableSize && !u.noResumable.Load() {
+			err = u.uploadResumable(ctx, uploadHash, content, &sess)
+		} else {
+			err = u.uploadOnce(ctx, uploadHash, content)
+		}
+		var rerr retryableError
+		retryable := errors.As(err, &rerr)
+		u.throttle.release(retryable && rerr.limited, rerr.after)
+		if err == nil {
+			return nil
+		} else if !retryable {
+			return err
+		}
+		delay = rerr.after
+		if delay <= 0 {
+			delay = backoff(attempt + 1)
+		}
+		slog.Debug("upload failed, retrying", "path", "/"+content.path, "hash", uploadHash, "attempt", attempt+1, "delay", delay, "err", err)
+	}
+	return fmt.Errorf("failed after %d attempts: %w", attempts, err)
+}
+
+// verifyContent rehashes the contents that will be uploaded.
+func verifyContent(uploadHash string, content *fileContent) error {
+	r, err := content.open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return fmt.Errorf("read contents: %w", err)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != uploadHash {
+		slog.Warn("contents don't match hash, not uploading", "path", "/"+content.path, "hash", uploadHash, "actual", got, "bytes", n)
+		return errors.New("contents don't match hash")
+	}
+	return nil
+}
+
+func (u *uploader) uploadOnce(ctx context.Context, uploadHash string, content *fileContent) error {
+	body, err := content.open()
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	_, err = u.post(ctx, u.uploadURL+"/"+uploadHash, http.Header{
+		"Content-Type": {"application/octet-stream"},
+	}, body, content.size)
+	return err
+}
+
+// post sends a single upload request with a body of n bytes, returning the response headers.
+// Network errors and 429/5xx responses are returned as a retryableError.
+func (u *uploader) post(ctx context.Context, url string, header http.Header, body io.Reader, n int64) (http.Header, error) {
+	reqCtx := ctx
+	if u.timeout > 0 {
+		var cancel context.CancelFunc
+		reqCtx, cancel = context.WithTimeout(ctx, u.timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, url, body)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.ContentLength = n
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	res, err := u.httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		} else if reqCtx.Err() != nil {
+			return nil, retryableError{err: fmt.Errorf("timed out after %v: %w", u.timeout, err)}
+		}
+		return nil, retryableError{err: err}
+	}
+	defer res.Body.Close()
+	if !u.loggedProto.Swap(true) {
+		slog.Info("upload connection", "proto", res.Proto)
+	}
+	if res.StatusCode != 200 {
+		// the start of the body usually explains the failure
+		body, _ := io.ReadAll(io.LimitReader(res.Body, maxErrorBody))
+		io.Copy(io.Discard, res.Body)
+		status := res.Status
+		if msg := strings.Join(strings.Fields(string(body)), " "); msg != "" {
+			status += ": " + msg
+		}
+		if res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden {
+			return nil, fmt.Errorf("%v: %w", status, errURLExpired)
+		} else if res.StatusCode == http.StatusTooManyRequests {
+			after := retryAfter(res.Header)
+			return nil, retryableError{err: uploadLimited(status, after), limited: true, after: after}
+		}
+		err := fmt.Errorf("unexpected response: %v", status)
+		if res.StatusCode >= 500 {
+			return nil, retryableError{err: err, after: retryAfter(res.Header)}
+		}
+		return nil, withClass(ErrRejected, err)
+	}
+	io.Copy(io.Discard, res.Body)
+	return res.Header, nil
+}
+
+// retryableError marks transient upload failures.
+type retryableError struct {
+	err error
+	// limited is set for rate limited uploads
+	limited bool
+	// after is the delay the server asked for before retrying
+	after time.Duration
+}
+
+func (e retryableError) Error() string { return e.err.Error() }
+func (e retryableError) Unwrap() error { return e.err }
+
+// backoff returns the delay before the given retry attempt (starting at 1),
+// doubling each time up to retryMaxDelay, with full jitter.
+func backoff(attempt int) time.Duration {
+	d := retryBaseDelay << (attempt - 1)
+	if d > retryMaxDelay || d <= 0 {
+		d = retryMaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(d)))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,82 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDeployDuplicateContents(t *testing.T) {
+	f := newFakeAPI(t)
+	config := writeSite(t, map[string]string{
+		"a.txt":     "same",
+		"dir/b.txt": "same",
+	})
+	res, err := f.deployer().Deploy(context.Background(), Options{Config: config})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the fake requires the hash once for each path
+	hash := gzipHash(t, "same")
+	if got := f.uploads(); !slices.Equal(got, []string{hash}) {
+		t.Errorf("uploaded %v, want a single upload of %s", got, hash)
+	}
+	sr := res.Sites[0]
+	if !slices.Equal(sr.UploadedHashes, []string{hash}) {
+		t.Errorf("result lists uploaded hashes %v, want %v", sr.UploadedHashes, []string{hash})
+	}
+	if sr.Uploaded != 2 {
+		t.Errorf("uploaded %d paths, want 2", sr.Uploaded)
+	}
+}
+
+func TestDeployResumesPartialUpload(t *testing.T) {
+	f := newFakeAPI(t)
+	files := map[string]string{
+		"a.txt": "first",
+		"b.txt": "second",
+		"c.txt": "third",
+		"d.txt": "fourth",
+	}
+	config := writeSite(t, files)
+	failed := gzipHash(t, "third")
+	f.failUploads[failed] = http.StatusBadRequest
+
+	o := Options{Config: config, Concurrency: 1}
+	_, err := f.deployer().Deploy(context.Background(), o)
+	if err == nil {
+		t.Fatal("deploy with a rejected upload succeeded")
+	}
+	first := f.uploads()
+	if want := fmt.Sprintf("%d of 4 uploads completed", len(first)); !strings.Contains(err.Error(), want) {
+		t.Errorf("error %q doesn't report %q", err, want)
+	}
+	if len(first) == 0 {
+		t.Fatal("no uploads completed before the rejected one")
+	} else if slices.Contains(first, failed) {
+		t.Errorf("rejected upload %s was stored", failed)
+	}
+
+	delete(f.failUploads, failed)
+	res, err := f.deployer().Deploy(context.Background(), o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := f.uploads()
+	var want []string
+	for _, content := range files {
+		if hash := gzipHash(t, content); !slices.Contains(first, hash) {
+			want = append(want, hash)
+		}
+	}
+	slices.Sort(want)
+	if !slices.Equal(second, want) {
+		t.Errorf("re-run uploaded %v, want only the remaining %v", second, want)
+	}
+	if sr := res.Sites[0]; sr.Uploaded != len(want) || sr.Skipped != len(first) {
+		t.Errorf("re-run uploaded %d, skipped %d, want %d and %d", sr.Uploaded, sr.Skipped, len(want), len(first))
+	}
+}
@@ -0,0 +1,76 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	firebasehosting "google.golang.org/api/firebasehosting/v1beta1"
+)
+
+// releasedVersions returns the versions currently released to any of the site's channels.
+func releasedVersions(ctx context.Context, client API, site string) ([]string, error) {
+	var versions []string
+	err := client.ListChannels(ctx, site, func(chs []*firebasehosting.Channel) error {
+		for _, ch := range chs {
+			if ch.Release != nil && ch.Release.Version != nil {
+				versions = append(versions, ch.Release.Version.Name)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list channels of %s: %w", site, err)
+	}
+	return versions, nil
+}
+
+// pruneVersions deletes the oldest finalized versions of site,
+// keeping the newest keep of them.
+// Versions in keepAlways, such as the ones currently released,
+// are never deleted and don't count towards keep.
+func pruneVersions(ctx context.Context, client API, site string, keep int, keepAlways ...string) error {
+	protected := make(map[string]bool)
+	for _, v := range keepAlways {
+		protected[v] = true
+	}
+
+	type version struct {
+		name    string
+		created time.Time
+	}
+	var versions []version
+	err := client.ListVersions(ctx, site, func(vs []*firebasehosting.Version) error {
+		for _, v := range vs {
+			if v.Status != "FINALIZED" || protected[v.Name] {
+				continue
+			}
+			created, err := time.Parse(time.RFC3339Nano, v.CreateTime)
+			if err != nil {
+				return fmt.Errorf("parse create time of %s: %w", v.Name, err)
+			}
+			versions = append(versions, version{v.Name, created})
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("list versions of %s: %w", site, err)
+	}
+	if len(versions) <= keep {
+		return nil
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].created.After(versions[j].created)
+	})
+	for _, v := range versions[keep:] {
+		err := client.DeleteVersion(ctx, v.name)
+		if err != nil {
+			return fmt.Errorf("delete old version %s: %w", v.name, err)
+		}
+		slog.Warn("deleted old version", "version", v.name, "created", v.created)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package deploy
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPruneVersionsKeepsReleased(t *testing.T) {
+	f := newFakeAPI(t)
+	config := writeSite(t, map[string]string{"index.html": "first"})
+	res, err := f.deployer().Deploy(context.Background(), Options{Config: config, Channel: "preview"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	preview := res.Sites[0].Version
+
+	var versions []string
+	for _, content := range []string{"second", "third", "fourth"} {
+		config := writeSite(t, map[string]string{"index.html": content})
+		res, err := f.deployer().Deploy(context.Background(), Options{Config: config, KeepVersions: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		versions = append(versions, res.Sites[0].Version)
+	}
+
+	if _, ok := f.versions[preview]; !ok {
+		t.Errorf("version %s released to a preview channel was deleted", preview)
+	}
+	for i, v := range versions {
+		_, ok := f.versions[v]
+		if want := i >= len(versions)-2; ok != want {
+			t.Errorf("version %s kept %v, want %v", v, ok, want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// webhookTimeout limits each webhook call.
+const webhookTimeout = 30 * time.Second
+
+// webhookPayload is the json body posted to the webhook after a release.
+type webhookPayload struct {
+	Site     string    `json:"site"`
+	Version  string    `json:"version"`
+	Release  string    `json:"release"`
+	Channel  string    `json:"channel"`
+	URL      string    `json:"url,omitempty"`
+	Files    int       `json:"files"`
+	Uploaded int       `json:"uploaded"`
+	Skipped  int       `json:"skipped"`
+	Time     time.Time `json:"time"`
+}
+
+// webhookClient sends requests with userAgent, through proxy if set,
+// otherwise the proxy from the environment.
+// It's separate from the upload client, so the deploy's credentials aren't sent elsewhere.
+func webhookClient(userAgent, proxy string) (*http.Client, error) {
+	transport, err := uploadTransport(1, proxy)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: &headerTransport{"User-Agent", userAgent, transport}}, nil
+}
+
+// callWebhook posts the outcome of a release to url with client.
+func callWebhook(ctx context.Context, client *http.Client, url, channel string, res *SiteResult) error {
+	if channel == "" {
+		channel = "live"
+	}
+	b, err := json.Marshal(webhookPayload{
+		Site:     res.Site,
+		Version:  res.Version,
+		Release:  res.Release,
+		Channel:  channel,
+		URL:      res.SiteURL + res.PreviewURL,
+		Files:    res.Uploaded + res.Skipped,
+		Uploaded: res.Uploaded,
+		Skipped:  res.Skipped,
+		Time:     time.Now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("encode webhook payload: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("call webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("call webhook: unexpected response: %v", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package deploy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestWebhookProxy(t *testing.T) {
+	var mu sync.Mutex
+	var got *http.Request
+	var payload webhookPayload
+	// http requests sent through a proxy have the absolute url of the target
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = r
+		json.NewDecoder(r.Body).Decode(&payload)
+	}))
+	defer proxy.Close()
+
+	f := newFakeAPI(t)
+	config := writeSite(t, map[string]string{"index.html": "<h1>hello</h1>"})
+	_, err := f.deployer().Deploy(context.Background(), Options{
+		Config:          config,
+		Webhook:         "http://hooks.example.com/deployed",
+		WebhookRequired: true,
+		Proxy:           proxy.URL,
+		UserAgent:       "test-agent",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if got == nil {
+		t.Fatal("webhook wasn't sent through the proxy")
+	}
+	if got.URL.String() != "http://hooks.example.com/deployed" {
+		t.Errorf("proxied request for %s", got.URL)
+	}
+	if ua := got.Header.Get("User-Agent"); ua != "test-agent" {
+		t.Errorf("User-Agent = %q, want test-agent", ua)
+	}
+	if auth := got.Header.Get("Authorization"); auth != "" {
+		t.Errorf("webhook sent credentials %q", auth)
+	}
+	if payload.Site != "sites/test" || payload.Channel != "live" {
+		t.Errorf("payload %+v, want sites/test on live", payload)
+	}
+}
@@ -1,6 +1,6 @@
 module go.seankhliao.com/fbhuploader
 
-go 1.20
+go 1.21
 
 require (
 	golang.org/x/oauth2 v0.0.0-20220822191816-0ebed06d0094
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"go.seankhliao.com/fbhuploader/deploy"
+	firebasehosting "google.golang.org/api/firebasehosting/v1beta1"
+)
+
+// list runs the versions and releases subcommands,
+// printing the history of each selected site.
+func list(cmd string, args []string) int {
+	var o options
+	fs := flag.NewFlagSet("fbhuploader "+cmd, flag.ExitOnError)
+	o.siteFlags(fs)
+	fs.BoolVar(&o.json, "json", false, "output as json")
+	fs.Parse(args)
+	o.setup()
+
+	ctx, stop := signalContext()
+	defer stop()
+	err := runList(ctx, cmd, o)
+	if err != nil {
+		printError(o.json, err)
+		return exitCode(err)
+	}
+	return 0
+}
+
+func runList(ctx context.Context, cmd string, o options) error {
+	do := o.deployOptions()
+	sites, err := deploy.Sites(do)
+	if err != nil {
+		return err
+	}
+
+	var d deploy.Deployer
+	versions := []*firebasehosting.Version{}
+	releases := []*firebasehosting.Release{}
+	for _, site := range sites {
+		if cmd == "versions" {
+			vs, err := d.Versions(ctx, do, site)
+			if err != nil {
+				return err
+			}
+			versions = append(versions, vs...)
+		} else {
+			rs, err := d.Releases(ctx, do, site)
+			if err != nil {
+				return err
+			}
+			releases = append(releases, rs...)
+		}
+	}
+
+	if o.json {
+		if cmd == "versions" {
+			return json.NewEncoder(os.Stdout).Encode(versions)
+		}
+		return json.NewEncoder(os.Stdout).Encode(releases)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	if cmd == "versions" {
+		fmt.Fprintln(w, "VERSION\tSTATUS\tCREATED\tFILES")
+		for _, v := range versions {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", v.Name, v.Status, v.CreateTime, v.FileCount)
+		}
+	} else {
+		fmt.Fprintln(w, "RELEASED\tVERSION\tTYPE\tUSER\tMESSAGE")
+		for _, r := range releases {
+			var version, user string
+			if r.Version != nil {
+				version = r.Version.Name
+			}
+			if r.ReleaseUser != nil {
+				user = r.ReleaseUser.Email
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", r.ReleaseTime, version, r.Type, user, r.Message)
+		}
+	}
+	return w.Flush()
+}
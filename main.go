@@ -1,233 +1,411 @@
 package main
 
 import (
-	"bytes"
-	"compress/gzip"
+	"bufio"
 	"context"
-	"crypto/sha256"
-	"encoding/hex"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
-	"io/fs"
-	"net/http"
+	"log/slog"
 	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
 
-	"golang.org/x/oauth2/google"
-	firebasehosting "google.golang.org/api/firebasehosting/v1beta1"
+	"go.seankhliao.com/fbhuploader/deploy"
 )
 
-func main() {
-	flag.Parse()
+type options struct {
+	config          string
+	site            string
+	target          string
+	public          stringsFlag
+	failFast        bool
+	project         string
+	credentials     string
+	credentialsJSON []byte
+	concurrency     int
+	readConcurrency int
+	retries         int
+	uploadTimeout   time.Duration
+	dryRun          bool
 
-	ctx := context.Background()
-	err := run(ctx)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
-	}
-}
+	channel        string
+	channelExpires time.Duration
 
-func run(ctx context.Context) error {
-	fbConfFile := "firebase.json"
+	json     bool
+	quiet    bool
+	manifest string
 
-	fbConf, err := readConfig(fbConfFile)
-	if err != nil {
-		return err
-	}
+	keepFailed    bool
+	precompressed bool
+	resume        bool
+	only          stringsFlag
+	mergeLive     bool
+	skipUnchanged bool
+	userAgent     string
+	proxy         string
+	diff          bool
+	strict        bool
+	checkIgnore   bool
+	strictIgnore  bool
+	include       stringsFlag
 
-	httpClient, err := google.DefaultClient(ctx, "https://www.googleapis.com/auth/cloud-platform", "https://www.googleapis.com/auth/firebase")
-	if err != nil {
-		return fmt.Errorf("create http client: %w", err)
-	}
+	headersFile   string
+	redirectsFile string
+	cacheControl  stringsFlag
+	archive       string
+	noRelease     bool
 
-	client, err := firebasehosting.NewService(ctx)
-	if err != nil {
-		return fmt.Errorf("create firebase client: %w", err)
-	}
+	timeout     time.Duration
+	verify      bool
+	verbose     bool
+	noCache     bool
+	message     string
+	releaseType string
+	wait        time.Duration
+	yes         bool
 
-	version, err := createVersion(ctx, client, fbConf)
-	if err != nil {
-		return err
-	}
+	allowEmpty       bool
+	compression      string
+	followSymlinks   bool
+	keepVersions     int
+	noLock           bool
+	maxFiles         int
+	maxFileSize      int64
+	extAllow         string
+	modifiedAfter    timeFlag
+	printConfig      bool
+	version          bool
+	noDefaultIgnores bool
 
-	pathToHash, hashToGzip, err := readFiles(ctx, fbConf)
-	if err != nil {
-		return err
-	}
+	baseURL       string
+	uploadBaseURL string
 
-	toUpload, uploadURL, err := getRequiredUploads(ctx, client, version, pathToHash)
-	if err != nil {
-		return err
-	}
+	webhook         string
+	webhookRequired bool
+}
 
-	err = uploadFiles(ctx, client, httpClient, version, toUpload, uploadURL, hashToGzip)
-	if err != nil {
-		return err
-	}
+// stringsFlag is a flag that can be repeated to collect multiple values.
+type stringsFlag []string
 
-	err = release(ctx, client, "sites/"+fbConf.Hosting.Site, version)
-	if err != nil {
-		return err
-	}
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
 
+func (f *stringsFlag) Set(s string) error {
+	*f = append(*f, s)
 	return nil
 }
 
-func readConfig(fbConfFile string) (*FirebaseJSON, error) {
-	b, err := os.ReadFile(fbConfFile)
-	if err != nil {
-		return nil, fmt.Errorf("read %s: %w", fbConfFile, err)
-	}
-	var fbConf FirebaseJSON
-	err = json.Unmarshal(b, &fbConf)
-	if err != nil {
-		return nil, fmt.Errorf("unmarshal %s: %w", fbConfFile, err)
+// splitList splits a comma separated list, returning nil for an empty string.
+func splitList(s string) []string {
+	if s == "" {
+		return nil
 	}
-	return &fbConf, nil
+	return strings.Split(s, ",")
 }
 
-func createVersion(ctx context.Context, client *firebasehosting.Service, fbConf *FirebaseJSON) (string, error) {
-	servingConf := &firebasehosting.ServingConfig{
-		CleanUrls: fbConf.Hosting.CleanURLs,
+// timeFlag is a flag for a date, or a date and time, in RFC 3339 format.
+type timeFlag struct{ time.Time }
+
+func (f *timeFlag) String() string {
+	if f.IsZero() {
+		return ""
 	}
-	if fbConf.Hosting.TrailingSlash {
-		servingConf.TrailingSlashBehavior = "ADD"
+	return f.Format(time.RFC3339)
+}
+
+func (f *timeFlag) Set(s string) error {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t, err = time.ParseInLocation(time.DateOnly, s, time.Local)
 	}
-	for _, header := range fbConf.Hosting.Headers {
-		hdrs := make(map[string]string)
-		for _, hdr := range header.Headers {
-			hdrs[hdr.Key] = hdr.Value
-		}
-		servingConf.Headers = append(servingConf.Headers, &firebasehosting.Header{
-			Glob:    header.Source,
-			Headers: hdrs,
-		})
-	}
-	for _, redirect := range fbConf.Hosting.Redirects {
-		servingConf.Redirects = append(servingConf.Redirects, &firebasehosting.Redirect{
-			Glob:       redirect.Source,
-			Location:   redirect.Destination,
-			StatusCode: int64(redirect.Type),
-		})
-	}
-
-	siteID := "sites/" + fbConf.Hosting.Site
-	version, err := client.Sites.Versions.Create(siteID, &firebasehosting.Version{
-		Config: servingConf,
-	}).Context(ctx).Do()
 	if err != nil {
-		return "", fmt.Errorf("create new version for %s: %w", siteID, err)
+		return fmt.Errorf("expected a date (2006-01-02) or time (2006-01-02T15:04:05Z07:00): %w", err)
 	}
-	return version.Name, nil
+	f.Time = t
+	return nil
 }
 
-func readFiles(ctx context.Context, fbConf *FirebaseJSON) (map[string]string, map[string]io.Reader, error) {
-	pathToHash := make(map[string]string)
-	hashToGzip := make(map[string]io.Reader)
-	dirFS := os.DirFS(fbConf.Hosting.Public)
-	err := fs.WalkDir(dirFS, ".", func(p string, d fs.DirEntry, err error) error {
-		if err != nil || d.IsDir() {
-			return err
-		}
-		// TODO: check not in ignores
-		f, err := dirFS.Open(p)
-		if err != nil {
-			return fmt.Errorf("open %s: %w", p, err)
+func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "versions", "releases":
+			os.Exit(list(os.Args[1], os.Args[2:]))
+		case "rollback":
+			os.Exit(rollback(os.Args[2:]))
+		case "delete-version":
+			os.Exit(deleteVersion(os.Args[2:]))
 		}
-		defer f.Close()
+	}
 
-		var buf bytes.Buffer
-		gw := gzip.NewWriter(&buf)
-		_, err = io.Copy(gw, f)
-		if err != nil {
-			return fmt.Errorf("read from %s: %w", p, err)
-		}
-		err = gw.Close()
-		if err != nil {
-			return fmt.Errorf("flush gzip writer for %s: %w", p, err)
-		}
-		sum := sha256.Sum256(buf.Bytes())
-		hash := hex.EncodeToString(sum[:])
-		pathToHash["/"+p] = hash
-		hashToGzip[hash] = &buf
+	var o options
+	o.siteFlags(flag.CommandLine)
+	flag.Var(&o.public, "public", "directory of files to deploy, overriding the config, repeat or comma separate to deploy several together")
+	flag.BoolVar(&o.failFast, "fail-fast", false, "with multiple hosting configs, stop after the first failed deploy")
+	flag.IntVar(&o.concurrency, "concurrency", deploy.DefaultConcurrency, "maximum number of files to upload in parallel, reduced while rate limited")
+	flag.IntVar(&o.readConcurrency, "read-concurrency", runtime.GOMAXPROCS(0), "number of files to compress and hash in parallel")
+	flag.IntVar(&o.retries, "retries", deploy.DefaultRetries, "maximum attempts for each file upload")
+	flag.DurationVar(&o.uploadTimeout, "upload-timeout", 0, "maximum duration of each attempt at uploading a file, timed out attempts are retried (default: no limit)")
+	flag.DurationVar(&o.uploadTimeout, "timeout-per-file", 0, "same as -upload-timeout")
+	flag.BoolVar(&o.dryRun, "dry-run", false, "report the files that would be uploaded without deploying")
+	flag.StringVar(&o.channel, "channel", "", "deploy to this preview channel instead of live")
+	flag.DurationVar(&o.channelExpires, "channel-expires", 0, "time until the preview channel expires (default: server default)")
+	flag.StringVar(&o.manifest, "manifest", "", "write the deployed paths, their hashes, and whether they were uploaded to this json file")
+	flag.BoolVar(&o.json, "json", false, "output the result as json")
+	flag.BoolVar(&o.quiet, "quiet", false, "don't report upload progress or links to the deployed site")
+	flag.BoolVar(&o.keepFailed, "keep-failed", false, "keep the created version if the deploy fails")
+	flag.BoolVar(&o.noRelease, "out-version", false, "finalize the version without releasing it and print its name, to release later with rollback -to")
+	flag.BoolVar(&o.noRelease, "no-release", false, "same as -out-version")
+	flag.StringVar(&o.archive, "archive", "", "deploy the files in this .zip, .tar, .tar.gz, or .tgz file instead of the public directory")
+	flag.StringVar(&o.headersFile, "headers-file", "", "json file of header rules to add to each site's config, replacing the headers of rules with the same source")
+	flag.StringVar(&o.redirectsFile, "redirects-file", "", "json file of redirect rules to add to each site's config, replacing rules with the same source")
+	flag.Var(&o.include, "include", "only deploy paths matching this glob, in addition to the include list in firebase.json (repeatable)")
+	flag.BoolVar(&o.strict, "strict", false, "fail on config mistakes that are otherwise warnings, like duplicate header or redirect sources")
+	flag.BoolVar(&o.checkIgnore, "check-ignore", false, "warn about files served by the live version that are now ignored and would be removed")
+	flag.BoolVar(&o.strictIgnore, "strict-ignore", false, "fail if files served by the live version are now ignored and would be removed")
+	flag.BoolVar(&o.diff, "diff", false, "list the new, changed, and deleted files compared to the live version, without deploying")
+	flag.StringVar(&o.userAgent, "user-agent", "", "appended to the User-Agent of requests, e.g. to identify a ci job")
+	flag.BoolVar(&o.skipUnchanged, "skip-unchanged", false, "don't deploy if the live version already has the same files and config")
+	flag.BoolVar(&o.skipUnchanged, "skip-if-unchanged", false, "same as -skip-unchanged")
+	flag.Var(&o.cacheControl, "cache", "set Cache-Control for paths matching a glob, as GLOB=VALUE, e.g. '**/*.js=public,max-age=31536000,immutable' (repeatable)")
+	flag.BoolVar(&o.resume, "resume", false, "continue an unfinished version left by an earlier failed deploy instead of creating a new one, keeping it on failure")
+	flag.BoolVar(&o.precompressed, "use-precompressed", false, "upload FILE.gz, if it exists, as the gzipped contents of FILE instead of compressing FILE")
+	flag.BoolVar(&o.mergeLive, "merge-with-live", false, "keep files of the live version that don't exist under public, instead of removing them")
+	flag.Var(&o.only, "only", "only deploy this file or directory under public, keeping the rest of the live version (repeatable)")
+	flag.DurationVar(&o.timeout, "timeout", 0, "maximum duration for the entire deploy (default: no limit)")
+	flag.BoolVar(&o.verify, "verify", false, "verify file contents match their hashes before uploading")
+	flag.BoolVar(&o.noCache, "no-cache", false, "recompute all file hashes instead of using .fbhuploader-cache.json")
+	flag.StringVar(&o.message, "message", "", "release message (default: the git commit of the config directory, if any)")
+	flag.StringVar(&o.releaseType, "release-type", "DEPLOY", "type recorded in the release history: DEPLOY or ROLLBACK")
+	flag.DurationVar(&o.wait, "wait", 0, "after releasing, wait up to this long for the channel to serve the new version")
+	flag.BoolVar(&o.yes, "yes", false, "don't ask for confirmation before releasing to live")
+	flag.BoolVar(&o.yes, "force", false, "alias for -yes")
+	flag.IntVar(&o.keepVersions, "keep-versions", 0, "after releasing to live, delete the oldest versions, keeping this many besides those released to a channel (default: keep all)")
+	flag.BoolVar(&o.noLock, "no-lock", false, "deploy even if another deploy to the same site is in progress")
+	flag.IntVar(&o.maxFiles, "max-files", deploy.DefaultMaxFiles, "fail before uploading if there are more files than this, negative for no limit")
+	flag.Int64Var(&o.maxFileSize, "max-file-size", 0, "skip files larger than this many bytes, listing them with -verbose")
+	flag.Var(&o.modifiedAfter, "modified-after", "skip files last modified before this date or time, listing them with -verbose")
+	flag.StringVar(&o.extAllow, "ext-allow", "", "comma separated file extensions, only deploy files with these, e.g. html,css,js")
+	flag.BoolVar(&o.allowEmpty, "allow-empty", false, "allow deploying a version with no files")
+	flag.StringVar(&o.compression, "compression", "default", "gzip compression level: default, speed, best, or 0-9. Changing it changes file hashes, uploading all files again")
+	flag.BoolVar(&o.noDefaultIgnores, "no-default-ignores", false, "don't ignore firebase.json, hidden files, and node_modules by default")
+	flag.BoolVar(&o.followSymlinks, "follow-symlinks", false, "deploy the contents of symlinked directories (symlinked files are always followed)")
+	flag.StringVar(&o.webhook, "webhook", "", "url to POST a json description of each release to")
+	flag.BoolVar(&o.webhookRequired, "webhook-required", false, "fail if the webhook call fails, instead of only warning")
+	flag.BoolVar(&o.printConfig, "print-config", false, "print the resolved hosting configs as json and exit, without deploying")
+	flag.BoolVar(&o.version, "version", false, "print the version and exit")
+	flag.Parse()
+	if o.version {
+		printVersion()
+		return
+	}
+	o.setup()
+	if o.printConfig {
+		os.Exit(printConfig(o.json, o.deployOptions()))
+	}
 
-		return nil
-	})
+	ctx, stop := signalContext()
+	defer stop()
+	if o.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.timeout)
+		defer cancel()
+	}
+	var d deploy.Deployer
+	res, err := d.Deploy(ctx, o.deployOptions())
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("deploy timed out after %v: %w", o.timeout, err)
+	}
+	if (res == nil || len(res.Sites) == 0) && err != nil {
+		printError(o.json, err)
+		os.Exit(exitCode(err))
+	}
+	printResults(o.json, o.quiet, res.Sites)
+	if o.manifest != "" {
+		merr := writeManifest(o.manifest, res.Sites)
+		if merr != nil {
+			printError(o.json, merr)
+			err = errors.Join(err, merr)
+		}
+	}
 	if err != nil {
-		return nil, nil, fmt.Errorf("walk %s: %w", fbConf.Hosting.Public, err)
+		os.Exit(exitCode(err))
 	}
-	return pathToHash, hashToGzip, nil
 }
 
-func getRequiredUploads(ctx context.Context, client *firebasehosting.Service, version string, pathToHash map[string]string) ([]string, string, error) {
-	populateResponse, err := client.Sites.Versions.PopulateFiles(version, &firebasehosting.PopulateVersionFilesRequest{
-		Files: pathToHash,
-	}).Context(ctx).Do()
+// printConfig prints the config that would be deployed, returning the exit code.
+func printConfig(asJSON bool, o deploy.Options) int {
+	conf, err := deploy.ResolveConfig(o)
 	if err != nil {
-		return nil, "", fmt.Errorf("get required uploads for %s: %w", version, err)
+		printError(asJSON, err)
+		return exitCode(err)
 	}
-	return populateResponse.UploadRequiredHashes, populateResponse.UploadUrl, nil
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(conf)
+	return 0
 }
 
-func uploadFiles(ctx context.Context, client *firebasehosting.Service, httpClient *http.Client, version string, toUpload []string, uploadURL string, hashToGzip map[string]io.Reader) error {
-	for _, uploadHash := range toUpload {
-		endpoint := uploadURL + "/" + uploadHash
-		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, hashToGzip[uploadHash])
-		if err != nil {
-			return fmt.Errorf("create request for %s: %w", uploadHash, err)
-		}
-		req.Header.Set("content-type", "application/octet-stream")
-		res, err := httpClient.Do(req)
+// siteFlags registers the flags shared by all commands,
+// selecting the sites to work on and how to access them.
+func (o *options) siteFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.config, "config", "firebase.json", "path to firebase.json, or - to read it from stdin")
+	fs.StringVar(&o.site, "site", "", "site to use, overriding the config (or selecting one of multiple hosting configs)")
+	fs.StringVar(&o.target, "target", "", "only use the hosting config for this target")
+	fs.StringVar(&o.project, "project", "", "firebase project the sites belong to, checked before deploying (default: $GOOGLE_CLOUD_PROJECT)")
+	fs.StringVar(&o.credentials, "credentials", "", "path to a service account key file (default: $FIREBASE_SA_B64 base64 decoded, or application default credentials)")
+	fs.StringVar(&o.proxy, "proxy", "", "url of a proxy for all requests (default: $HTTPS_PROXY, except for $NO_PROXY)")
+	fs.BoolVar(&o.verbose, "verbose", false, "log each step")
+}
+
+// setup applies the defaults that come from the environment
+// and configures logging, after flags are parsed.
+func (o *options) setup() {
+	if o.project == "" {
+		o.project = os.Getenv("GOOGLE_CLOUD_PROJECT")
+	}
+	// undocumented, for testing against a fake server
+	o.baseURL = os.Getenv("FBHUPLOADER_BASE_URL")
+	o.uploadBaseURL = os.Getenv("FBHUPLOADER_UPLOAD_BASE_URL")
+	level := slog.LevelWarn
+	if o.verbose {
+		level = slog.LevelDebug
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+
+	if b64 := os.Getenv("FIREBASE_SA_B64"); b64 != "" && o.credentials == "" {
+		var err error
+		o.credentialsJSON, err = decodeCredentials(b64)
 		if err != nil {
-			return fmt.Errorf("upload for %s: %w", uploadHash, err)
-		}
-		if res.StatusCode != 200 {
-			return fmt.Errorf("unexpected response for upload %s: %v", uploadHash, res.Status)
+			printError(o.json, fmt.Errorf("%w: FIREBASE_SA_B64: %v", deploy.ErrConfig, err))
+			os.Exit(exitCode(deploy.ErrConfig))
 		}
-		defer res.Body.Close()
-		io.Copy(io.Discard, res.Body)
 	}
+}
 
-	patchResponse, err := client.Sites.Versions.Patch(version, &firebasehosting.Version{
-		Status: "FINALIZED",
-	}).Context(ctx).Do()
+// decodeCredentials decodes base64 encoded credentials json,
+// ignoring whitespace and missing padding.
+func decodeCredentials(b64 string) ([]byte, error) {
+	b64 = strings.TrimRight(strings.Join(strings.Fields(b64), ""), "=")
+	b, err := base64.RawStdEncoding.DecodeString(b64)
 	if err != nil {
-		return fmt.Errorf("finalize %s: %w", version, err)
+		return nil, fmt.Errorf("invalid base64: %w", err)
+	} else if !json.Valid(b) {
+		return nil, errors.New("decoded credentials aren't json")
 	}
-	if patchResponse.Status != "FINALIZED" {
-		return fmt.Errorf("unexpected finalization status: %v", patchResponse.Status)
+	return b, nil
+}
+
+// exitCode distinguishes the classes of failures,
+// so scripts can decide whether to retry.
+// With multiple failed sites, the first matching class is used.
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, deploy.ErrConfig):
+		return 2
+	case errors.Is(err, deploy.ErrAuth):
+		return 3
+	case errors.Is(err, deploy.ErrTransient):
+		return 4
+	case errors.Is(err, deploy.ErrRejected):
+		return 5
 	}
-	return nil
+	return 1
 }
 
-func release(ctx context.Context, client *firebasehosting.Service, site, version string) error {
-	_, err := client.Sites.Releases.Create(site, &firebasehosting.Release{}).VersionName(version).Context(ctx).Do()
-	if err != nil {
-		return fmt.Errorf("release %s: %w", version, err)
+// deployOptions converts the command line flags to deploy options.
+func (o options) deployOptions() deploy.Options {
+	publics := splitList(o.public.String())
+	if len(publics) == 0 {
+		publics = []string{""}
 	}
-	return nil
+	do := deploy.Options{
+		Config:           o.config,
+		Site:             o.site,
+		Target:           o.target,
+		Public:           publics[0],
+		ExtraPublic:      publics[1:],
+		FailFast:         o.failFast,
+		Project:          o.project,
+		Credentials:      o.credentials,
+		CredentialsJSON:  o.credentialsJSON,
+		Concurrency:      o.concurrency,
+		ReadConcurrency:  o.readConcurrency,
+		Retries:          o.retries,
+		UploadTimeout:    o.uploadTimeout,
+		Compression:      o.compression,
+		UsePrecompressed: o.precompressed,
+		DryRun:           o.dryRun,
+		Diff:             o.diff,
+		MaxFiles:         o.maxFiles,
+		MaxFileSize:      o.maxFileSize,
+		Extensions:       splitList(o.extAllow),
+		ModifiedAfter:    o.modifiedAfter.Time,
+		AllowEmpty:       o.allowEmpty,
+		Only:             o.only,
+		MergeLive:        o.mergeLive,
+		Strict:           o.strict,
+		CheckIgnore:      o.checkIgnore,
+		StrictIgnore:     o.strictIgnore,
+		Include:          o.include,
+		HeadersFile:      o.headersFile,
+		RedirectsFile:    o.redirectsFile,
+		CacheControl:     o.cacheControl,
+		Archive:          o.archive,
+		NoRelease:        o.noRelease,
+		SkipUnchanged:    o.skipUnchanged,
+		NoLock:           o.noLock,
+		KeepFailed:       o.keepFailed,
+		Resume:           o.resume,
+		Verify:           o.verify,
+		FollowSymlinks:   o.followSymlinks,
+		NoDefaultIgnores: o.noDefaultIgnores,
+		NoCache:          o.noCache,
+		Channel:          o.channel,
+		ChannelExpires:   o.channelExpires,
+		Message:          o.message,
+		ReleaseType:      o.releaseType,
+		KeepVersions:     o.keepVersions,
+		Wait:             o.wait,
+		UserAgent:        userAgent(o.userAgent),
+		Proxy:            o.proxy,
+		Webhook:          o.webhook,
+		WebhookRequired:  o.webhookRequired,
+		BaseURL:          o.baseURL,
+		UploadBaseURL:    o.uploadBaseURL,
+	}
+	if !o.yes && deploy.IsTerminal(os.Stdin) {
+		do.Confirm = confirm
+	}
+	if !o.quiet {
+		do.Progress = os.Stderr
+	}
+	return do
+}
+
+// signalContext returns a context cancelled on an interrupt or SIGTERM,
+// so in flight requests are stopped and failed versions cleaned up.
+// A second interrupt exits immediately, e.g. at a confirmation prompt.
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	context.AfterFunc(ctx, func() { stop() })
+	return ctx, stop
 }
 
-type FirebaseJSON struct {
-	Hosting struct {
-		Site          string   `json:"site"`
-		Public        string   `json:"public"`
-		Ignore        []string `json:"ignore"`
-		CleanURLs     bool     `json:"cleanUrls"`
-		TrailingSlash bool     `json:"trailingSlash"`
-		Headers       []struct {
-			Source  string `json:"source"`
-			Headers []struct {
-				Key   string `json:"key"`
-				Value string `json:"value"`
-			} `json:"headers"`
-		} `json:"headers"`
-		Redirects []struct {
-			Source      string `json:"source"`
-			Destination string `json:"destination"`
-			Type        int    `json:"type"`
-		} `json:"redirects"`
-	} `json:"hosting"`
+// confirm asks the user a yes/no question on the terminal.
+func confirm(question string) (bool, error) {
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, fmt.Errorf("read confirmation: %w", err)
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
 }
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"go.seankhliao.com/fbhuploader/deploy"
+)
+
+// printResults outputs the result of each deploy.
+// As json, a single deploy is output as an object, multiple as an array.
+// quiet omits the links from the text output.
+func printResults(asJSON, quiet bool, results []*deploy.SiteResult) {
+	if asJSON {
+		if len(results) == 1 {
+			json.NewEncoder(os.Stdout).Encode(results[0])
+		} else {
+			json.NewEncoder(os.Stdout).Encode(results)
+		}
+		return
+	}
+	for _, res := range results {
+		printResult(quiet, res)
+	}
+}
+
+func printResult(quiet bool, res *deploy.SiteResult) {
+	if res.Error != "" {
+		fmt.Fprintln(os.Stderr, res.Error)
+		return
+	}
+	if res.Unchanged {
+		fmt.Printf("no changes to %s, nothing to deploy\n", res.Site)
+		return
+	}
+	if res.Diff != nil {
+		printDiff(quiet, res)
+		return
+	}
+	if res.DryRun {
+		for _, p := range res.Files {
+			fmt.Println("upload", p)
+		}
+		fmt.Printf("dry run: would upload %d files, %d unchanged, and create a new release for %s\n", res.Uploaded, res.Skipped, res.Site)
+		printCompression(res)
+		return
+	}
+	if res.Release == "" {
+		// not released, output only the version for scripts to release later
+		fmt.Println(res.Version)
+		return
+	}
+	fmt.Printf("released %s: uploaded %d files (%d bytes), %d unchanged, in %v\n", res.Version, res.Uploaded, res.Bytes, res.Skipped, res.Elapsed.Round(time.Millisecond))
+	printCompression(res)
+	if quiet {
+		return
+	}
+	if res.PreviewURL != "" {
+		fmt.Println("preview:", res.PreviewURL)
+	} else if res.SiteURL != "" {
+		fmt.Println("site:", res.SiteURL)
+	}
+	if res.DashboardURL != "" {
+		fmt.Println("dashboard:", res.DashboardURL)
+	}
+}
+
+// printDiff lists the new, changed, and deleted paths,
+// quiet only prints the counts.
+func printDiff(quiet bool, res *deploy.SiteResult) {
+	d := res.Diff
+	if !quiet {
+		for _, c := range []struct {
+			prefix string
+			paths  []string
+		}{{"new", d.New}, {"changed", d.Changed}, {"deleted", d.Deleted}} {
+			for _, p := range c.paths {
+				fmt.Println(c.prefix, p)
+			}
+		}
+	}
+	fmt.Printf("diff against live %s: %d new, %d changed, %d deleted, %d unchanged\n", res.Site, len(d.New), len(d.Changed), len(d.Deleted), len(d.Unchanged))
+}
+
+func printCompression(res *deploy.SiteResult) {
+	if res.RawBytes == 0 {
+		return
+	}
+	fmt.Printf("compressed %d bytes to %d (%.1f%% smaller)\n", res.RawBytes, res.CompressedBytes, res.SavedPercent())
+}
+
+// manifest is the document written by -manifest for each site.
+type manifest struct {
+	Site    string                         `json:"site"`
+	Version string                         `json:"version,omitempty"`
+	Files   map[string]deploy.ManifestFile `json:"files"`
+}
+
+// writeManifest writes the files of each successful deploy to file as json,
+// as an object for a single site, or an array for multiple.
+func writeManifest(file string, results []*deploy.SiteResult) error {
+	var manifests []manifest
+	for _, res := range results {
+		if res.Error == "" {
+			manifests = append(manifests, manifest{res.Site, res.Version, res.Manifest})
+		}
+	}
+	var v any = manifests
+	if len(manifests) == 1 {
+		v = manifests[0]
+	}
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode manifest: %w", err)
+	}
+	err = os.WriteFile(file, append(b, '\n'), 0o644)
+	if err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+	return nil
+}
+
+func printError(asJSON bool, err error) {
+	if asJSON {
+		json.NewEncoder(os.Stdout).Encode(struct {
+			Error string `json:"error"`
+		}{err.Error()})
+		return
+	}
+	fmt.Fprintln(os.Stderr, err)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.seankhliao.com/fbhuploader/deploy"
+)
+
+// rollback runs the rollback subcommand,
+// releasing a previous version of a site to live again.
+func rollback(args []string) int {
+	var o options
+	var to string
+	fs := flag.NewFlagSet("fbhuploader rollback", flag.ExitOnError)
+	o.siteFlags(fs)
+	fs.StringVar(&to, "to", "", "version to release, as sites/SITE/versions/VERSION or VERSION (default: the previously released version)")
+	fs.StringVar(&o.message, "message", "", "release message (default: rollback to VERSION)")
+	fs.StringVar(&o.releaseType, "release-type", "ROLLBACK", "type recorded in the release history: DEPLOY or ROLLBACK")
+	fs.BoolVar(&o.yes, "yes", false, "don't ask for confirmation before releasing")
+	fs.BoolVar(&o.yes, "force", false, "alias for -yes")
+	fs.BoolVar(&o.json, "json", false, "output the release as json")
+	fs.Parse(args)
+	o.setup()
+
+	ctx, stop := signalContext()
+	defer stop()
+	err := runRollback(ctx, o, to)
+	if err != nil {
+		printError(o.json, err)
+		return exitCode(err)
+	}
+	return 0
+}
+
+func runRollback(ctx context.Context, o options, to string) error {
+	do := o.deployOptions()
+	site, err := versionSite(do, "rollback", to)
+	if err != nil {
+		return err
+	}
+
+	var d deploy.Deployer
+	rel, err := d.Rollback(ctx, do, site, to)
+	if err != nil {
+		return err
+	}
+	if o.json {
+		return json.NewEncoder(os.Stdout).Encode(rel)
+	}
+	fmt.Println("released", rel.Name)
+	return nil
+}
+
+// versionSite returns the site of version if it's a full version name,
+// otherwise the single site selected by o, for cmd.
+func versionSite(o deploy.Options, cmd, version string) (string, error) {
+	if rest, ok := strings.CutPrefix(version, "sites/"); ok {
+		site, _, _ := strings.Cut(rest, "/")
+		return site, nil
+	}
+	sites, err := deploy.Sites(o)
+	if err != nil {
+		return "", err
+	} else if len(sites) != 1 {
+		return "", fmt.Errorf("%w: %s needs a single site, select one with -site or -target, or use a full version name", deploy.ErrConfig, cmd)
+	}
+	return sites[0], nil
+}
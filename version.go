@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Set with -ldflags "-X main.version=... -X main.commit=... -X main.date=...",
+// otherwise they're read from the build info.
+var (
+	version string
+	commit  string
+	date    string
+)
+
+// printVersion prints the module version, vcs commit, and build date.
+func printVersion() {
+	v, c, d := buildInfo()
+	fmt.Printf("fbhuploader %s\ncommit: %s\ndate: %s\ngo: %s\n", v, c, d, runtime.Version())
+}
+
+// userAgent identifies requests with the version, followed by suffix if set.
+func userAgent(suffix string) string {
+	v, _, _ := buildInfo()
+	ua := "fbhuploader/" + v
+	if suffix != "" {
+		ua += " " + suffix
+	}
+	return ua
+}
+
+// buildInfo returns the module version, vcs commit, and build date.
+func buildInfo() (v, c, d string) {
+	v, c, d = version, commit, date
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if v == "" {
+			v = info.Main.Version
+		}
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if c == "" {
+					c = s.Value
+				}
+			case "vcs.time":
+				if d == "" {
+					d = s.Value
+				}
+			case "vcs.modified":
+				if s.Value == "true" && commit == "" {
+					c += "-dirty"
+				}
+			}
+		}
+	}
+	for _, s := range []*string{&v, &c, &d} {
+		if *s == "" {
+			*s = "unknown"
+		}
+	}
+	return v, c, d
+}